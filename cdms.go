@@ -1,10 +1,19 @@
 package main
 
 import (
+    "crypto/sha256"
+    "encoding/hex"
     "encoding/json"
     "fmt"
     "log"
+    "math/bits"
+    "reflect"
+    "regexp"
+    "sort"
+    "strings"
+    "time"
 
+    "github.com/hyperledger/fabric-chaincode-go/shim"
     "github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
@@ -23,10 +32,23 @@ type Record struct {
     OffChainURI string `json:"offChainUri"`
     OwnerOrg    string `json:"ownerOrg"`
     CreatedAt   string `json:"createdAt"`
+    UpdatedAt   string `json:"updatedAt,omitempty"`
+    UpdatedBy   string `json:"updatedBy,omitempty"`
     PolicyID    string `json:"policyId"`
-    Description string `json:"description"`
+    Description string `json:"description,omitempty"` // private; only filled in by QueryRecord
+    DescriptionHash string `json:"descriptionHash,omitempty"`
+    Status      string `json:"status,omitempty"` // empty on records created before RetireRecord existed; treated as active
+    StatusReason string `json:"statusReason,omitempty"`
+    Version     int    `json:"version"` // current file version, see AddRecordVersion
+    HashAlgorithm string `json:"hashAlgorithm,omitempty"` // algorithm of FileHash; empty means SHA-256
 }
 
+// Record statuses
+const (
+    RecordStatusActive  = "active"
+    RecordStatusRetired = "retired"
+)
+
 // (rules removed) Using simplified policy: AllowedOrgs and AllowedRoles arrays
 
 type Policy struct {
@@ -35,8 +57,15 @@ type Policy struct {
     Categories []string `json:"categories"`
     AllowedOrgs  []string `json:"allowedOrgs,omitempty"`
     AllowedRoles []string `json:"allowedRoles,omitempty"`
+    // Deny lists override the allow lists above
+    DeniedOrgs   []string `json:"deniedOrgs,omitempty"`
+    DeniedRoles  []string `json:"deniedRoles,omitempty"`
+    DeniedUsers  []string `json:"deniedUsers,omitempty"` // usernames or client identity IDs
     CreatedAt  string   `json:"createdAt"`
     CreatedBy  string   `json:"createdBy"`
+    UpdatedAt  string   `json:"updatedAt,omitempty"` // for a stored version: when it took effect
+    UpdatedBy  string   `json:"updatedBy,omitempty"`
+    Version    int      `json:"version"`
 }
 
 type Organization struct {
@@ -45,6 +74,8 @@ type Organization struct {
     Name    string   `json:"name"`
     MspID   string   `json:"mspId"`
     Members []string `json:"members"`
+    UpdatedAt string `json:"updatedAt,omitempty"`
+    UpdatedBy string `json:"updatedBy,omitempty"`
 }
 
 type User struct {
@@ -56,20 +87,670 @@ type User struct {
     Organization string `json:"organization"`
     PasswordHash string `json:"passwordHash"` // stored bcrypt hash (backend must supply)
     CreatedAt    string `json:"createdAt"`
+    UpdatedAt    string `json:"updatedAt,omitempty"`
+    UpdatedBy    string `json:"updatedBy,omitempty"`
+    CertID       string `json:"certId,omitempty"` // X.509 identity bound with BindUserIdentity
+    Status       string `json:"status"`
+    StatusReason string `json:"statusReason,omitempty"`
 }
 
+// User statuses. Users stored before Status existed have an empty status and are treated as active.
+const (
+    UserStatusActive    = "active"
+    UserStatusSuspended = "suspended"
+)
+
 type Case struct {
     DocType     string `json:"docType"`
     ID          string `json:"id"`
     Title       string `json:"title"`
-    Description string `json:"description"`
+    Description string `json:"description,omitempty"` // private; only filled in by QueryCase
+    DescriptionHash string `json:"descriptionHash,omitempty"`
     Status      string `json:"status"`
     Jurisdiction string `json:"jurisdiction"`
     CaseType    string `json:"caseType"`
     CreatedBy   string `json:"createdBy"`
     CreatedAt   string `json:"createdAt"`
+    UpdatedAt   string `json:"updatedAt,omitempty"`
+    UpdatedBy   string `json:"updatedBy,omitempty"`
     Organization string `json:"organization"`
     PolicyID    string `json:"policyId"`     // Policy controlling access to this case
+    StatusHistory []CaseStatusChange `json:"statusHistory,omitempty"`
+}
+
+// CaseStatusChange records a single status transition of a case.
+type CaseStatusChange struct {
+    From         string `json:"from"`
+    To           string `json:"to"`
+    Reason       string `json:"reason"`
+    ChangedBy    string `json:"changedBy"`
+    ChangedByMSP string `json:"changedByMsp"`
+    ChangedAt    string `json:"changedAt"`
+    TxID         string `json:"txId"`
+}
+
+// Case statuses (must match the backend's CreateCaseDto)
+const (
+    CaseStatusOpen               = "Open"
+    CaseStatusUnderInvestigation = "Under Investigation"
+    CaseStatusClosed             = "Closed"
+    CaseStatusArchived           = "Archived" // set only by ArchiveCase
+)
+
+// caseStatusTransitions lists the statuses reachable through UpdateCaseStatus.
+// A closed case can only be moved back to Open through ReopenCase.
+var caseStatusTransitions = map[string][]string{
+    CaseStatusOpen:               {CaseStatusUnderInvestigation, CaseStatusClosed},
+    CaseStatusUnderInvestigation: {CaseStatusOpen, CaseStatusClosed},
+    CaseStatusClosed:             {},
+    CaseStatusArchived:           {},
+}
+
+// RecordVersion is one revision of a record's file, as returned by QueryRecordVersions.
+type RecordVersion struct {
+    RecordID    string `json:"recordId"`
+    Version     int    `json:"version"`
+    FileHash    string `json:"fileHash"`
+    OffChainURI string `json:"offChainUri"`
+    Note        string `json:"note,omitempty"`
+    AddedBy     string `json:"addedBy,omitempty"`
+    AddedByMSP  string `json:"addedByMsp,omitempty"`
+    AddedAt     string `json:"addedAt"`
+    TxID        string `json:"txId,omitempty"`
+}
+
+// EvidenceBatch anchors the Merkle root of a set of files, e.g. a bulk digital seizure, in one transaction.
+type EvidenceBatch struct {
+    DocType       string `json:"docType"`
+    BatchID       string `json:"batchId"` // ID of the anchoring transaction
+    CaseID        string `json:"caseId"`
+    MerkleRoot    string `json:"merkleRoot"`
+    LeafCount     int    `json:"leafCount"`
+    ManifestURI   string `json:"manifestUri"`
+    HashAlgorithm string `json:"hashAlgorithm"`
+    AnchoredBy    string `json:"anchoredBy"`
+    AnchoredByMSP string `json:"anchoredByMsp"`
+    AnchoredAt    string `json:"anchoredAt"`
+}
+
+// Case relation types, read as "caseA <relation> caseB"
+const (
+    CaseLinkRelatedTo    = "related-to"
+    CaseLinkParentOf     = "parent-of"
+    CaseLinkChildOf      = "child-of"
+    CaseLinkDuplicateOf  = "duplicate-of"
+    CaseLinkDuplicatedBy = "duplicated-by"
+)
+
+// caseLinkInverses maps each relation type to the relation seen from the other case.
+var caseLinkInverses = map[string]string{
+    CaseLinkRelatedTo:    CaseLinkRelatedTo,
+    CaseLinkParentOf:     CaseLinkChildOf,
+    CaseLinkChildOf:      CaseLinkParentOf,
+    CaseLinkDuplicateOf:  CaseLinkDuplicatedBy,
+    CaseLinkDuplicatedBy: CaseLinkDuplicateOf,
+}
+
+// CaseLink is one direction of a link between two cases.
+type CaseLink struct {
+    CaseID       string `json:"caseId"`
+    LinkedCaseID string `json:"linkedCaseId"`
+    RelationType string `json:"relationType"` // CaseID <RelationType> LinkedCaseID
+    LinkedBy     string `json:"linkedBy"`
+    LinkedByMSP  string `json:"linkedByMsp"`
+    LinkedAt     string `json:"linkedAt"`
+    TxID         string `json:"txId"`
+}
+
+// CustodyEvent is one entry of a record's chain of custody.
+type CustodyEvent struct {
+    RecordID     string `json:"recordId"`
+    FromOrg      string `json:"fromOrg"` // empty for the initial custodian
+    ToOrg        string `json:"toOrg"`
+    SubmittedBy  string `json:"submittedBy"`
+    SubmitterMSP string `json:"submitterMsp"`
+    Reason       string `json:"reason"`
+    TxID         string `json:"txId"`
+    Timestamp    string `json:"timestamp"`
+}
+
+// AccessGrant gives a single user access to one record or case until ExpiresAt, regardless of its policy's allow lists.
+type AccessGrant struct {
+    DocType      string `json:"docType"`
+    RecordID     string `json:"recordId,omitempty"`
+    CaseID       string `json:"caseId,omitempty"`
+    Grantee      string `json:"grantee"` // username or client identity ID
+    ExpiresAt    string `json:"expiresAt"`
+    GrantedBy    string `json:"grantedBy"`
+    GrantedByMSP string `json:"grantedByMsp"`
+    GrantedAt    string `json:"grantedAt"`
+    RequestID    string `json:"requestId,omitempty"` // set when the grant comes from an approved access request
+}
+
+// AccessRequest is a user's on-ledger request for access to a record or case held by another organization.
+type AccessRequest struct {
+    DocType       string `json:"docType"`
+    RequestID     string `json:"requestId"`
+    ResourceType  string `json:"resourceType"` // "record" or "case"
+    ResourceID    string `json:"resourceId"`
+    OwnerOrg      string `json:"ownerOrg"`
+    Requester     string `json:"requester"` // username, or client identity ID if none is bound
+    RequesterMSP  string `json:"requesterMsp"`
+    Justification string `json:"justification"`
+    Status        string `json:"status"`
+    ExpiresAt     string `json:"expiresAt,omitempty"` // expiry of the grant made on approval
+    RequestedAt   string `json:"requestedAt"`
+    Steps         []AccessRequestStep `json:"steps"`
+}
+
+// AccessRequestStep records one step of an access request: its submission, approval or rejection.
+type AccessRequestStep struct {
+    Status    string `json:"status"`
+    Reason    string `json:"reason,omitempty"`
+    By        string `json:"by"`
+    ByMSP     string `json:"byMsp"`
+    At        string `json:"at"`
+    TxID      string `json:"txId"`
+}
+
+// Access request statuses
+const (
+    AccessRequestPending  = "pending"
+    AccessRequestApproved = "approved"
+    AccessRequestRejected = "rejected"
+)
+
+// AuditEntry records one read of a record or case through AccessRecord or AccessCase, or one VerifyRecordHash.
+type AuditEntry struct {
+    DocType      string `json:"docType"`
+    ResourceType string `json:"resourceType"` // "record" or "case"
+    ResourceID   string `json:"resourceId"`
+    Action       string `json:"action"` // "read" or "verify"
+    Detail       string `json:"detail,omitempty"` // e.g. the outcome of a hash verification
+    CallerID     string `json:"callerId"`
+    Username     string `json:"username,omitempty"`
+    MSPID        string `json:"mspId"`
+    Role         string `json:"role"`
+    TxID         string `json:"txId"`
+    Timestamp    string `json:"timestamp"`
+    Decision     string `json:"decision"`
+    Reason       string `json:"reason,omitempty"` // why access was denied
+}
+
+// LegalHold blocks archival and deletion of a case while it is in place.
+type LegalHold struct {
+    DocType     string `json:"docType"`
+    HoldID      string `json:"holdId"`
+    CaseID      string `json:"caseId"`
+    Reason      string `json:"reason"`
+    PlacedBy    string `json:"placedBy"`
+    PlacedByMSP string `json:"placedByMsp"`
+    PlacedAt    string `json:"placedAt"`
+}
+
+// CaseDeletionApproval is one organization's approval to hard delete an archived case.
+type CaseDeletionApproval struct {
+    CaseID     string `json:"caseId"`
+    MSPID      string `json:"mspId"`
+    ApprovedBy string `json:"approvedBy"`
+    ApprovedAt string `json:"approvedAt"`
+    TxID       string `json:"txId"`
+}
+
+// CaseHistoryEntry is one committed version of a case, as returned by GetCaseHistory.
+type CaseHistoryEntry struct {
+    TxID      string `json:"txId"`
+    Timestamp string `json:"timestamp"`
+    IsDelete  bool   `json:"isDelete"`
+    Value     *Case  `json:"value,omitempty"` // nil when the entry is a delete
+}
+
+// RecordHistoryEntry is one committed version of a record, as returned by GetRecordHistory.
+type RecordHistoryEntry struct {
+    TxID      string  `json:"txId"`
+    Timestamp string  `json:"timestamp"`
+    IsDelete  bool    `json:"isDelete"`
+    Value     *Record `json:"value,omitempty"` // nil when the entry is a delete
+}
+
+// --------------------------- PAGINATION ------------------------------
+// Page types returned by the *WithPagination transactions. Bookmark is empty on the last page and
+// FetchedCount is the number of ledger entries read for the page, before any policy filtering.
+
+type PaginatedCases struct {
+    Results      []*Case `json:"results"`
+    Bookmark     string  `json:"bookmark"`
+    FetchedCount int32   `json:"fetchedCount"`
+}
+
+type PaginatedRecords struct {
+    Results      []*Record `json:"results"`
+    Bookmark     string    `json:"bookmark"`
+    FetchedCount int32     `json:"fetchedCount"`
+}
+
+type PaginatedPolicies struct {
+    Results      []*Policy `json:"results"`
+    Bookmark     string    `json:"bookmark"`
+    FetchedCount int32     `json:"fetchedCount"`
+}
+
+type PaginatedOrganizations struct {
+    Results      []*Organization `json:"results"`
+    Bookmark     string          `json:"bookmark"`
+    FetchedCount int32           `json:"fetchedCount"`
+}
+
+// --------------------------- HELPERS ---------------------------------
+
+func validatePageSize(pageSize int32) error {
+    if pageSize <= 0 {
+        return fmt.Errorf("pageSize must be greater than zero, got %d", pageSize)
+    }
+    return nil
+}
+
+// txTimestamp returns the transaction timestamp in RFC3339. It is identical on every endorsing peer.
+func txTimestamp(ctx contractapi.TransactionContextInterface) (string, error) {
+    ts, err := ctx.GetStub().GetTxTimestamp()
+    if err != nil {
+        return "", fmt.Errorf("failed to get transaction timestamp: %v", err)
+    }
+    return ts.AsTime().UTC().Format(time.RFC3339), nil
+}
+
+// txAuthor returns the transaction timestamp and the submitting identity, for UpdatedAt/UpdatedBy.
+func txAuthor(ctx contractapi.TransactionContextInterface) (string, string, error) {
+    now, err := txTimestamp(ctx)
+    if err != nil {
+        return "", "", err
+    }
+    clientID, err := ctx.GetClientIdentity().GetID()
+    if err != nil {
+        return "", "", fmt.Errorf("failed to get client ID: %v", err)
+    }
+    return now, clientID, nil
+}
+
+// --------------------------- PRIVATE DATA ----------------------------
+// Descriptions of cases and records are kept in private data collections (see collections_config.json)
+// so that only member organizations can read them. Public state keeps a SHA-256 hash of a secret salt
+// followed by the description, which lets members prove the text existed without letting anyone else
+// guess short descriptions from the hash. The salt comes from the client in the transient map under
+// "descriptionSalt" (endorsing peers must agree on it, so it cannot be drawn in the chaincode) and is
+// kept next to the description in the collection.
+
+const (
+    caseDetailsCollection   = "caseDetailsCollection"
+    recordDetailsCollection = "recordDetailsCollection"
+    minDescriptionSaltBytes = 16
+)
+
+// PrivateDetails is the value stored in a private data collection under the entity's key.
+type PrivateDetails struct {
+    ID          string `json:"id"`
+    Description string `json:"description"`
+    Salt        string `json:"salt,omitempty"` // hex; empty for descriptions stored before salting
+}
+
+// privateDescription returns the description supplied through the transient map (key "description").
+// Positional arguments are recorded in the block, so a non-empty positional description is rejected;
+// the argument is kept only for backward compatibility of the transaction signatures.
+func privateDescription(ctx contractapi.TransactionContextInterface, argument string) (string, error) {
+    if argument != "" {
+        return "", fmt.Errorf("description must be sent in the transient map under \"description\", not as an argument")
+    }
+    transient, err := ctx.GetStub().GetTransient()
+    if err != nil {
+        return "", fmt.Errorf("failed to read transient data: %v", err)
+    }
+    return string(transient["description"]), nil
+}
+
+// putPrivateDescription stores the description and its salt in the collection and returns the salted
+// hash to keep in public state.
+func putPrivateDescription(ctx contractapi.TransactionContextInterface, collection, key, id, description string) (string, error) {
+    if description == "" {
+        return "", ctx.GetStub().DelPrivateData(collection, key)
+    }
+    transient, err := ctx.GetStub().GetTransient()
+    if err != nil {
+        return "", fmt.Errorf("failed to read transient data: %v", err)
+    }
+    salt := transient["descriptionSalt"]
+    if len(salt) < minDescriptionSaltBytes {
+        return "", fmt.Errorf("a random salt of at least %d bytes must be sent in the transient map under \"descriptionSalt\"", minDescriptionSaltBytes)
+    }
+    detailsJSON, err := json.Marshal(PrivateDetails{ID: id, Description: description, Salt: hex.EncodeToString(salt)})
+    if err != nil {
+        return "", err
+    }
+    if err := ctx.GetStub().PutPrivateData(collection, key, detailsJSON); err != nil {
+        return "", fmt.Errorf("failed to write private data to %s: %v", collection, err)
+    }
+    sum := sha256.Sum256(append(append([]byte{}, salt...), description...))
+    return hex.EncodeToString(sum[:]), nil
+}
+
+// getPrivateDescription reads a description back. Clients whose organization is not a member of the
+// collection (or peers without the data) get an empty string rather than an error.
+func getPrivateDescription(ctx contractapi.TransactionContextInterface, collection, key string) string {
+    detailsJSON, err := ctx.GetStub().GetPrivateData(collection, key)
+    if err != nil || detailsJSON == nil {
+        return ""
+    }
+    var details PrivateDetails
+    if err := json.Unmarshal(detailsJSON, &details); err != nil {
+        return ""
+    }
+    return details.Description
+}
+
+// --------------------------- EVENTS ----------------------------------
+// Chaincode events let the backend subscribe through the gateway instead of polling. Payloads carry
+// identifiers and routing fields only: no descriptions, titles, file locations or user credentials.
+// Fabric keeps a single event per transaction, so each transaction emits exactly one.
+
+const (
+    EventCaseCreated           = "CaseCreated"
+    EventCaseStatusChanged     = "CaseStatusChanged"
+    EventCaseDeleted           = "CaseDeleted"
+    EventCaseDeletionApproved  = "CaseDeletionApproved"
+    EventLegalHoldPlaced       = "LegalHoldPlaced"
+    EventLegalHoldReleased     = "LegalHoldReleased"
+    EventCasesLinked           = "CasesLinked"
+    EventCasesUnlinked         = "CasesUnlinked"
+    EventRecordCreated         = "RecordCreated"
+    EventRecordMetadataUpdated = "RecordMetadataUpdated"
+    EventRecordRetired         = "RecordRetired"
+    EventRecordVersionAdded    = "RecordVersionAdded"
+    EventEvidenceBatchAnchored = "EvidenceBatchAnchored"
+    EventCustodyTransferred    = "CustodyTransferred"
+    EventRecordAccessGranted   = "RecordAccessGranted"
+    EventRecordAccessRevoked   = "RecordAccessRevoked"
+    EventCaseAccessRevoked     = "CaseAccessRevoked"
+    EventAccessRequested       = "AccessRequested"
+    EventAccessRequestApproved = "AccessRequestApproved"
+    EventAccessRequestRejected = "AccessRequestRejected"
+    EventResourceAccessed      = "ResourceAccessed"
+    EventPolicyCreated         = "PolicyCreated"
+    EventPolicyUpdated         = "PolicyUpdated"
+    EventUserCreated           = "UserCreated"
+    EventUserIdentityBound     = "UserIdentityBound"
+    EventUserRoleUpdated       = "UserRoleUpdated"
+    EventUserSuspended         = "UserSuspended"
+    EventUserReactivated       = "UserReactivated"
+    EventOrganizationCreated   = "OrganizationCreated"
+    EventOrganizationUpdated   = "OrganizationUpdated"
+    EventOrgMemberAdded        = "OrganizationMemberAdded"
+    EventOrgMemberRemoved      = "OrganizationMemberRemoved"
+)
+
+type CaseEventPayload struct {
+    CaseID       string `json:"caseId"`
+    Status       string `json:"status,omitempty"`
+    Organization string `json:"organization,omitempty"`
+    PolicyID     string `json:"policyId,omitempty"`
+    TxID         string `json:"txId"`
+}
+
+type RecordEventPayload struct {
+    RecordID      string   `json:"recordId"`
+    CaseID        string   `json:"caseId"`
+    RecordType    string   `json:"recordType"`
+    OwnerOrg      string   `json:"ownerOrg"`
+    PolicyID      string   `json:"policyId"`
+    UpdatedFields []string `json:"updatedFields,omitempty"`
+    TxID          string   `json:"txId"`
+}
+
+type CaseLinkEventPayload struct {
+    CaseID       string `json:"caseId"`
+    LinkedCaseID string `json:"linkedCaseId"`
+    RelationType string `json:"relationType"`
+    TxID         string `json:"txId"`
+}
+
+type BatchEventPayload struct {
+    BatchID    string `json:"batchId"`
+    CaseID     string `json:"caseId"`
+    MerkleRoot string `json:"merkleRoot"`
+    LeafCount  int    `json:"leafCount"`
+    TxID       string `json:"txId"`
+}
+
+type LegalHoldEventPayload struct {
+    CaseID string `json:"caseId"`
+    HoldID string `json:"holdId"`
+    TxID   string `json:"txId"`
+}
+
+type GrantEventPayload struct {
+    RecordID  string `json:"recordId,omitempty"`
+    CaseID    string `json:"caseId,omitempty"`
+    Grantee   string `json:"grantee"`
+    ExpiresAt string `json:"expiresAt,omitempty"`
+    TxID      string `json:"txId"`
+}
+
+type AccessRequestEventPayload struct {
+    RequestID    string `json:"requestId"`
+    ResourceType string `json:"resourceType"`
+    ResourceID   string `json:"resourceId"`
+    OwnerOrg     string `json:"ownerOrg"`
+    Requester    string `json:"requester"`
+    Status       string `json:"status"`
+    ExpiresAt    string `json:"expiresAt,omitempty"`
+    TxID         string `json:"txId"`
+}
+
+type AuditEventPayload struct {
+    ResourceType string `json:"resourceType"`
+    ResourceID   string `json:"resourceId"`
+    Action       string `json:"action"`
+    Decision     string `json:"decision"`
+    MSPID        string `json:"mspId"`
+    TxID         string `json:"txId"`
+}
+
+type PolicyEventPayload struct {
+    PolicyID  string `json:"policyId"`
+    CreatedBy string `json:"createdBy"`
+    Version   int    `json:"version"`
+    TxID      string `json:"txId"`
+}
+
+type OrganizationEventPayload struct {
+    OrgID  string `json:"orgId"`
+    MspID  string `json:"mspId"`
+    Member string `json:"member,omitempty"`
+    TxID   string `json:"txId"`
+}
+
+type UserEventPayload struct {
+    Username     string `json:"username"`
+    Role         string `json:"role"`
+    Organization string `json:"organization"`
+    Status       string `json:"status,omitempty"`
+    TxID         string `json:"txId"`
+}
+
+func emitEvent(ctx contractapi.TransactionContextInterface, name string, payload interface{}) error {
+    payloadJSON, err := json.Marshal(payload)
+    if err != nil {
+        return fmt.Errorf("failed to marshal %s event: %v", name, err)
+    }
+    if err := ctx.GetStub().SetEvent(name, payloadJSON); err != nil {
+        return fmt.Errorf("failed to set %s event: %v", name, err)
+    }
+    return nil
+}
+
+// --------------------------- FILTERS ---------------------------------
+// Filters are small Mango-style selectors evaluated inside the chaincode, so they behave the same on
+// LevelDB and CouchDB peers. A filter is a JSON object keyed by document field name (as in the json
+// tags). A plain value means equality; an object value uses operators: {"$eq": v}, {"$in": [v1, v2]},
+// {"$prefix": "abc"}, {"$gt": v}, {"$gte": v}, {"$lt": v}, {"$lte": v}. The "$and" and "$or" keys
+// take an array of nested filters. Strings compare lexically, so RFC3339 timestamps order correctly.
+
+// parseFilters decodes a filters JSON string. An empty string or empty object means no filtering.
+func parseFilters(filtersJSON string) (map[string]interface{}, error) {
+    if strings.TrimSpace(filtersJSON) == "" {
+        return nil, nil
+    }
+    var filters map[string]interface{}
+    if err := json.Unmarshal([]byte(filtersJSON), &filters); err != nil {
+        return nil, fmt.Errorf("invalid filters JSON: %v", err)
+    }
+    return filters, nil
+}
+
+// toDocument converts an entity to the generic JSON map the filter evaluator works on.
+func toDocument(v interface{}) (map[string]interface{}, error) {
+    raw, err := json.Marshal(v)
+    if err != nil {
+        return nil, err
+    }
+    var doc map[string]interface{}
+    if err := json.Unmarshal(raw, &doc); err != nil {
+        return nil, err
+    }
+    return doc, nil
+}
+
+// matchesFilters reports whether doc satisfies every filter.
+func matchesFilters(doc map[string]interface{}, filters map[string]interface{}) (bool, error) {
+    for field, condition := range filters {
+        if field == "$and" || field == "$or" {
+            matched, err := matchesCombination(doc, field, condition)
+            if err != nil {
+                return false, err
+            }
+            if !matched {
+                return false, nil
+            }
+            continue
+        }
+
+        // The backend sends unset filters as null or ""
+        if condition == nil || condition == "" {
+            continue
+        }
+
+        value := doc[field]
+        operators, isObject := condition.(map[string]interface{})
+        if !isObject {
+            if !reflect.DeepEqual(value, condition) {
+                return false, nil
+            }
+            continue
+        }
+
+        for op, operand := range operators {
+            ok, err := applyFilterOperator(field, value, op, operand)
+            if err != nil {
+                return false, err
+            }
+            if !ok {
+                return false, nil
+            }
+        }
+    }
+    return true, nil
+}
+
+// matchesCombination evaluates an "$and" / "$or" array of nested filters.
+func matchesCombination(doc map[string]interface{}, op string, condition interface{}) (bool, error) {
+    clauses, ok := condition.([]interface{})
+    if !ok {
+        return false, fmt.Errorf("%s expects an array of filters", op)
+    }
+    for _, clause := range clauses {
+        sub, ok := clause.(map[string]interface{})
+        if !ok {
+            return false, fmt.Errorf("%s expects an array of filters", op)
+        }
+        matched, err := matchesFilters(doc, sub)
+        if err != nil {
+            return false, err
+        }
+        if op == "$or" && matched {
+            return true, nil
+        }
+        if op == "$and" && !matched {
+            return false, nil
+        }
+    }
+    // An empty $and matches everything; an empty $or matches nothing
+    return op == "$and", nil
+}
+
+func applyFilterOperator(field string, value interface{}, op string, operand interface{}) (bool, error) {
+    switch op {
+    case "$eq":
+        return reflect.DeepEqual(value, operand), nil
+    case "$in":
+        candidates, ok := operand.([]interface{})
+        if !ok {
+            return false, fmt.Errorf("filter on %s: $in expects an array", field)
+        }
+        for _, c := range candidates {
+            if reflect.DeepEqual(value, c) {
+                return true, nil
+            }
+        }
+        return false, nil
+    case "$prefix":
+        prefix, ok := operand.(string)
+        if !ok {
+            return false, fmt.Errorf("filter on %s: $prefix expects a string", field)
+        }
+        str, ok := value.(string)
+        return ok && strings.HasPrefix(str, prefix), nil
+    case "$gt", "$gte", "$lt", "$lte":
+        cmp, comparable := compareFilterValues(value, operand)
+        if !comparable {
+            return false, nil
+        }
+        switch op {
+        case "$gt":
+            return cmp > 0, nil
+        case "$gte":
+            return cmp >= 0, nil
+        case "$lt":
+            return cmp < 0, nil
+        default:
+            return cmp <= 0, nil
+        }
+    default:
+        return false, fmt.Errorf("filter on %s: unsupported operator %s", field, op)
+    }
+}
+
+// compareFilterValues orders two JSON values of the same kind (numbers or strings).
+// Values of different or unordered kinds are not comparable.
+func compareFilterValues(a, b interface{}) (int, bool) {
+    switch av := a.(type) {
+    case float64:
+        bv, ok := b.(float64)
+        if !ok {
+            return 0, false
+        }
+        switch {
+        case av < bv:
+            return -1, true
+        case av > bv:
+            return 1, true
+        }
+        return 0, true
+    case string:
+        bv, ok := b.(string)
+        if !ok {
+            return 0, false
+        }
+        return strings.Compare(av, bv), true
+    }
+    return 0, false
 }
 
 // --------------------------- POLICIES --------------------------------
@@ -101,6 +782,10 @@ func (s *SmartContract) CreatePolicy(ctx contractapi.TransactionContextInterface
     }
 
     clientMSPID, _ := ctx.GetClientIdentity().GetMSPID()
+    now, clientID, err := txAuthor(ctx)
+    if err != nil {
+        return err
+    }
 
     policy := Policy{
         DocType:    "policy",
@@ -109,72 +794,321 @@ func (s *SmartContract) CreatePolicy(ctx contractapi.TransactionContextInterface
         AllowedOrgs:  allowedOrgs,
         AllowedRoles: allowedRoles,
         CreatedBy:  clientMSPID,
-        CreatedAt:  "auto-generated",
+        CreatedAt:  now,
+        UpdatedAt:  now,
+        UpdatedBy:  clientID,
+        Version:    1,
     }
 
-    policyJSON, err := json.Marshal(policy)
+    if err := putPolicy(ctx, &policy); err != nil {
+        return err
+    }
+    return emitEvent(ctx, EventPolicyCreated, PolicyEventPayload{
+        PolicyID:  policyId,
+        CreatedBy: clientMSPID,
+        Version:   policy.Version,
+        TxID:      ctx.GetStub().GetTxID(),
+    })
+}
+
+// UpdatePolicy replaces a policy's categories and allow lists and bumps its Version. Every version stays
+// readable through QueryPolicyVersion. Only an admin of the organization that created the policy may update it.
+func (s *SmartContract) UpdatePolicy(ctx contractapi.TransactionContextInterface, policyId string, categoriesJSON string, allowedOrgsJSON string, allowedRolesJSON string) error {
+    policy, err := s.QueryPolicy(ctx, policyId)
     if err != nil {
         return err
     }
+    if err := s.requireOrgAdmin(ctx, policy.CreatedBy); err != nil {
+        return err
+    }
+
+    var categories []string
+    if err := json.Unmarshal([]byte(categoriesJSON), &categories); err != nil {
+        return fmt.Errorf("failed to unmarshal categories JSON: %v", err)
+    }
+
+    var allowedOrgs []string
+    if err := json.Unmarshal([]byte(allowedOrgsJSON), &allowedOrgs); err != nil {
+        return fmt.Errorf("failed to unmarshal allowedOrgs JSON: %v", err)
+    }
+
+    var allowedRoles []string
+    if err := json.Unmarshal([]byte(allowedRolesJSON), &allowedRoles); err != nil {
+        return fmt.Errorf("failed to unmarshal allowedRoles JSON: %v", err)
+    }
 
-    return ctx.GetStub().PutState(key, policyJSON)
+    policy.Categories = categories
+    policy.AllowedOrgs = allowedOrgs
+    policy.AllowedRoles = allowedRoles
+    return s.savePolicyVersion(ctx, policy)
 }
 
-// QueryPolicy returns policy details
-func (s *SmartContract) QueryPolicy(ctx contractapi.TransactionContextInterface, policyId string) (*Policy, error) {
-    key := "policy:" + policyId
-    policyJSON, err := ctx.GetStub().GetState(key)
+// UpdatePolicyDenyRules replaces a policy's deny lists and bumps its Version. A caller matching any deny
+// list is refused even if the allow lists match. Pass "[]" to clear a list.
+func (s *SmartContract) UpdatePolicyDenyRules(ctx contractapi.TransactionContextInterface, policyId string, deniedOrgsJSON string, deniedRolesJSON string, deniedUsersJSON string) error {
+    policy, err := s.QueryPolicy(ctx, policyId)
     if err != nil {
-        return nil, fmt.Errorf("failed to read policy from world state: %v", err)
+        return err
     }
-    if policyJSON == nil {
-        return nil, fmt.Errorf("the policy %s does not exist", policyId)
+    if err := s.requireOrgAdmin(ctx, policy.CreatedBy); err != nil {
+        return err
     }
-    var policy Policy
-    if err := json.Unmarshal(policyJSON, &policy); err != nil {
-        return nil, err
+
+    var deniedOrgs []string
+    if err := json.Unmarshal([]byte(deniedOrgsJSON), &deniedOrgs); err != nil {
+        return fmt.Errorf("failed to unmarshal deniedOrgs JSON: %v", err)
     }
 
-    // No additional normalization required for simplified policy
+    var deniedRoles []string
+    if err := json.Unmarshal([]byte(deniedRolesJSON), &deniedRoles); err != nil {
+        return fmt.Errorf("failed to unmarshal deniedRoles JSON: %v", err)
+    }
 
-    return &policy, nil
-}
+    var deniedUsers []string
+    if err := json.Unmarshal([]byte(deniedUsersJSON), &deniedUsers); err != nil {
+        return fmt.Errorf("failed to unmarshal deniedUsers JSON: %v", err)
+    }
 
-// QueryAllPolicies returns all policies
-func (s *SmartContract) QueryAllPolicies(ctx contractapi.TransactionContextInterface) ([]*Policy, error) {
-    // --- FIX for LevelDB ---
-    // Use GetStateByRange instead of GetQueryResult
-    startKey := "policy:"
-    endKey := "policy:\uffff"
+    policy.DeniedOrgs = deniedOrgs
+    policy.DeniedRoles = deniedRoles
+    policy.DeniedUsers = deniedUsers
+    return s.savePolicyVersion(ctx, policy)
+}
 
-    resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, endKey)
+// savePolicyVersion stores policy as the next version of itself and emits PolicyUpdated.
+func (s *SmartContract) savePolicyVersion(ctx contractapi.TransactionContextInterface, policy *Policy) error {
+    // Policies written before versioning have no stored version 1; keep the previous state as that version
+    previousKey, err := policyVersionKey(ctx, policy.PolicyID, policy.Version)
     if err != nil {
-        return nil, fmt.Errorf("failed to execute policy query: %v", err)
+        return err
     }
-    defer resultsIterator.Close()
-
-    var policies []*Policy
-    for resultsIterator.HasNext() {
-        qr, err := resultsIterator.Next()
+    previous, err := ctx.GetStub().GetState(previousKey)
+    if err != nil {
+        return fmt.Errorf("failed to read policy version: %v", err)
+    }
+    if previous == nil {
+        current, err := s.QueryPolicy(ctx, policy.PolicyID)
         if err != nil {
-            return nil, err
+            return err
         }
-        var p Policy
-        if err := json.Unmarshal(qr.Value, &p); err != nil {
-            return nil, err
+        if err := putPolicy(ctx, current); err != nil {
+            return err
         }
+    }
 
-            // simplified policy object - just append if docType matches
-            if p.DocType == "policy" {
-                policies = append(policies, &p)
-            }
+    policy.Version++
+    policy.UpdatedAt, policy.UpdatedBy, err = txAuthor(ctx)
+    if err != nil {
+        return err
     }
-    return policies, nil
+    if err := putPolicy(ctx, policy); err != nil {
+        return err
+    }
+    return emitEvent(ctx, EventPolicyUpdated, PolicyEventPayload{
+        PolicyID:  policy.PolicyID,
+        CreatedBy: policy.CreatedBy,
+        Version:   policy.Version,
+        TxID:      ctx.GetStub().GetTxID(),
+    })
 }
 
-// --------------------------- ORGANIZATIONS ---------------------------
-
-// --- NEW FUNCTION for LevelDB ---
+// QueryPolicyVersion returns a specific version of a policy. Compare the versions' updatedAt
+// (when each took effect) to find the version that governed an access decision at a given time.
+func (s *SmartContract) QueryPolicyVersion(ctx contractapi.TransactionContextInterface, policyId string, version int) (*Policy, error) {
+    key, err := policyVersionKey(ctx, policyId, version)
+    if err != nil {
+        return nil, err
+    }
+    policyJSON, err := ctx.GetStub().GetState(key)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read policy version: %v", err)
+    }
+    if policyJSON == nil {
+        // Policies written before versioning only have their current state
+        current, err := s.QueryPolicy(ctx, policyId)
+        if err == nil && current.Version == version {
+            return current, nil
+        }
+        return nil, fmt.Errorf("version %d of policy %s does not exist", version, policyId)
+    }
+    var policy Policy
+    if err := json.Unmarshal(policyJSON, &policy); err != nil {
+        return nil, err
+    }
+    return &policy, nil
+}
+
+// QueryPolicyVersions returns every stored version of a policy, oldest first.
+func (s *SmartContract) QueryPolicyVersions(ctx contractapi.TransactionContextInterface, policyId string) ([]*Policy, error) {
+    resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(policyVersionIndex, []string{policyId})
+    if err != nil {
+        return nil, fmt.Errorf("failed to execute policy version query: %v", err)
+    }
+    defer resultsIterator.Close()
+
+    return collectPolicies(resultsIterator)
+}
+
+// policyVersionIndex keys policy snapshots by [policyId, zero-padded version] so they sort by version.
+const policyVersionIndex = "policy~version"
+
+func policyVersionKey(ctx contractapi.TransactionContextInterface, policyId string, version int) (string, error) {
+    key, err := ctx.GetStub().CreateCompositeKey(policyVersionIndex, []string{policyId, fmt.Sprintf("%010d", version)})
+    if err != nil {
+        return "", fmt.Errorf("failed to create %s key: %v", policyVersionIndex, err)
+    }
+    return key, nil
+}
+
+// policyAllowsCategory reports whether recordType is one of the policy's Categories. A "*" entry allows any type.
+func policyAllowsCategory(policy *Policy, recordType string) bool {
+    for _, c := range policy.Categories {
+        if c == recordType || c == "*" {
+            return true
+        }
+    }
+    return false
+}
+
+// policyListMatches reports whether value is in list. A "*" entry matches anything.
+func policyListMatches(list []string, value string) bool {
+    for _, v := range list {
+        if v == value || v == "*" {
+            return true
+        }
+    }
+    return false
+}
+
+// policyAllowsOrg reports whether the policy lets organization mspId hold data, ignoring roles and users.
+func policyAllowsOrg(policy *Policy, mspId string) bool {
+    return policyListMatches(policy.AllowedOrgs, mspId) && !policyListMatches(policy.DeniedOrgs, mspId)
+}
+
+// evaluatePolicy is the policy half of the access decision (see evaluateAccess for grants). Deny rules override:
+// a caller whose org, role or user matches a deny list is refused even when the allow lists match.
+// Otherwise both the org and the role must be allowed.
+func evaluatePolicy(policy *Policy, c *caller) error {
+    if err := policyDenial(policy, c); err != nil {
+        return err
+    }
+    if !policyListMatches(policy.AllowedOrgs, c.MSPID) || !policyListMatches(policy.AllowedRoles, c.Role) {
+        return fmt.Errorf("access denied by policy for organization %s and role %s", c.MSPID, c.Role)
+    }
+    return nil
+}
+
+// policyDenial returns an error if the caller matches one of the policy's deny lists.
+func policyDenial(policy *Policy, c *caller) error {
+    if policyListMatches(policy.DeniedOrgs, c.MSPID) {
+        return fmt.Errorf("access denied by policy %s: organization %s is denied", policy.PolicyID, c.MSPID)
+    }
+    if policyListMatches(policy.DeniedRoles, c.Role) {
+        return fmt.Errorf("access denied by policy %s: role %s is denied", policy.PolicyID, c.Role)
+    }
+    if (c.Username != "" && policyListMatches(policy.DeniedUsers, c.Username)) || policyListMatches(policy.DeniedUsers, c.ID) {
+        return fmt.Errorf("access denied by policy %s: user is denied", policy.PolicyID)
+    }
+    return nil
+}
+
+// putPolicy writes the current policy and its versioned snapshot.
+func putPolicy(ctx contractapi.TransactionContextInterface, policy *Policy) error {
+    policyJSON, err := json.Marshal(policy)
+    if err != nil {
+        return err
+    }
+    if err := ctx.GetStub().PutState("policy:"+policy.PolicyID, policyJSON); err != nil {
+        return err
+    }
+    versionKey, err := policyVersionKey(ctx, policy.PolicyID, policy.Version)
+    if err != nil {
+        return err
+    }
+    return ctx.GetStub().PutState(versionKey, policyJSON)
+}
+
+// QueryPolicy returns policy details
+func (s *SmartContract) QueryPolicy(ctx contractapi.TransactionContextInterface, policyId string) (*Policy, error) {
+    key := "policy:" + policyId
+    policyJSON, err := ctx.GetStub().GetState(key)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read policy from world state: %v", err)
+    }
+    if policyJSON == nil {
+        return nil, fmt.Errorf("the policy %s does not exist", policyId)
+    }
+    var policy Policy
+    if err := json.Unmarshal(policyJSON, &policy); err != nil {
+        return nil, err
+    }
+
+    // Policies written before versioning are version 1
+    if policy.Version == 0 {
+        policy.Version = 1
+    }
+
+    return &policy, nil
+}
+
+// QueryAllPolicies returns all policies
+func (s *SmartContract) QueryAllPolicies(ctx contractapi.TransactionContextInterface) ([]*Policy, error) {
+    // --- FIX for LevelDB ---
+    // Use GetStateByRange instead of GetQueryResult
+    startKey := "policy:"
+    endKey := "policy:\uffff"
+
+    resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, endKey)
+    if err != nil {
+        return nil, fmt.Errorf("failed to execute policy query: %v", err)
+    }
+    defer resultsIterator.Close()
+
+    return collectPolicies(resultsIterator)
+}
+
+// QueryAllPoliciesWithPagination returns one page of policies. Pass the returned bookmark to get the next page.
+func (s *SmartContract) QueryAllPoliciesWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*PaginatedPolicies, error) {
+    if err := validatePageSize(pageSize); err != nil {
+        return nil, err
+    }
+
+    resultsIterator, metadata, err := ctx.GetStub().GetStateByRangeWithPagination("policy:", "policy:\uffff", pageSize, bookmark)
+    if err != nil {
+        return nil, fmt.Errorf("failed to execute policy query: %v", err)
+    }
+    defer resultsIterator.Close()
+
+    policies, err := collectPolicies(resultsIterator)
+    if err != nil {
+        return nil, err
+    }
+    return &PaginatedPolicies{Results: policies, Bookmark: metadata.Bookmark, FetchedCount: metadata.FetchedRecordsCount}, nil
+}
+
+func collectPolicies(resultsIterator shim.StateQueryIteratorInterface) ([]*Policy, error) {
+    var policies []*Policy
+    for resultsIterator.HasNext() {
+        qr, err := resultsIterator.Next()
+        if err != nil {
+            return nil, err
+        }
+        var p Policy
+        if err := json.Unmarshal(qr.Value, &p); err != nil {
+            return nil, err
+        }
+
+        // simplified policy object - just append if docType matches
+        if p.DocType == "policy" {
+            policies = append(policies, &p)
+        }
+    }
+    return policies, nil
+}
+
+// --------------------------- ORGANIZATIONS ---------------------------
+
+// --- NEW FUNCTION for LevelDB ---
 // QueryAllOrganizations returns all organizations
 func (s *SmartContract) QueryAllOrganizations(ctx contractapi.TransactionContextInterface) ([]*Organization, error) {
     startKey := "org:"
@@ -186,6 +1120,29 @@ func (s *SmartContract) QueryAllOrganizations(ctx contractapi.TransactionContext
     }
     defer resultsIterator.Close()
 
+    return collectOrganizations(resultsIterator)
+}
+
+// QueryAllOrganizationsWithPagination returns one page of organizations.
+func (s *SmartContract) QueryAllOrganizationsWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*PaginatedOrganizations, error) {
+    if err := validatePageSize(pageSize); err != nil {
+        return nil, err
+    }
+
+    resultsIterator, metadata, err := ctx.GetStub().GetStateByRangeWithPagination("org:", "org:\uffff", pageSize, bookmark)
+    if err != nil {
+        return nil, fmt.Errorf("failed to execute org query: %v", err)
+    }
+    defer resultsIterator.Close()
+
+    orgs, err := collectOrganizations(resultsIterator)
+    if err != nil {
+        return nil, err
+    }
+    return &PaginatedOrganizations{Results: orgs, Bookmark: metadata.Bookmark, FetchedCount: metadata.FetchedRecordsCount}, nil
+}
+
+func collectOrganizations(resultsIterator shim.StateQueryIteratorInterface) ([]*Organization, error) {
     var orgs []*Organization
     for resultsIterator.HasNext() {
         qr, err := resultsIterator.Next()
@@ -196,9 +1153,9 @@ func (s *SmartContract) QueryAllOrganizations(ctx contractapi.TransactionContext
         if err := json.Unmarshal(qr.Value, &o); err != nil {
             return nil, err
         }
-        
+
         if o.DocType == "org" {
-             orgs = append(orgs, &o)
+            orgs = append(orgs, &o)
         }
     }
     return orgs, nil
@@ -229,503 +1186,3587 @@ func (s *SmartContract) QueryOrganizationMembers(ctx contractapi.TransactionCont
     return org.Members, nil
 }
 
-// --------------------------- USERS ---------------------------------
-
-// CreateUser stores a user including a password hash.
-// passwordHash must be generated and provided by backend (bcrypt).
-func (s *SmartContract) CreateUser(ctx contractapi.TransactionContextInterface, username, fullName, email, role, organization, passwordHash string) error {
-    key := "user:" + username
+// CreateOrganization registers an organization. mspId must be the submitting client's MSP and the
+// caller must be an admin of it.
+func (s *SmartContract) CreateOrganization(ctx contractapi.TransactionContextInterface, orgId, name, mspId string) error {
+    key := "org:" + orgId
     exists, err := ctx.GetStub().GetState(key)
     if err != nil {
-        return fmt.Errorf("failed to check user: %v", err)
+        return fmt.Errorf("failed to check organization: %v", err)
     }
     if exists != nil {
-        return fmt.Errorf("user %s already exists", username)
+        return fmt.Errorf("organization %s already exists", orgId)
     }
-
-    user := User{
-        DocType:      "user",
-        Username:     username,
-        FullName:     fullName,
-        Email:        email,
-        Role:         role,
-        Organization: organization,
-        PasswordHash: passwordHash,
-        CreatedAt:    "auto-generated",
+    if err := s.requireOrgAdmin(ctx, mspId); err != nil {
+        return err
     }
-
-    userJSON, err := json.Marshal(user)
+    // findOrganization resolves MSP IDs, so each MSP may be registered only once
+    orgs, err := s.QueryAllOrganizations(ctx)
     if err != nil {
         return err
     }
+    for _, other := range orgs {
+        if other.MspID == mspId {
+            return fmt.Errorf("MSP %s is already registered as organization %s", mspId, other.OrgID)
+        }
+    }
 
-    return ctx.GetStub().PutState(key, userJSON)
+    org := &Organization{
+        DocType: "org",
+        OrgID:   orgId,
+        Name:    name,
+        MspID:   mspId,
+        Members: []string{},
+    }
+    if err := putOrganization(ctx, org); err != nil {
+        return err
+    }
+    return emitEvent(ctx, EventOrganizationCreated, OrganizationEventPayload{OrgID: orgId, MspID: mspId, TxID: ctx.GetStub().GetTxID()})
 }
 
-// QueryUser returns a user (including PasswordHash for auth verification by backend).
-func (s *SmartContract) QueryUser(ctx contractapi.TransactionContextInterface, username string) (*User, error) {
-    key := "user:" + username
-    userJSON, err := ctx.GetStub().GetState(key)
+// UpdateOrganization renames an organization. The MSP ID cannot be changed.
+func (s *SmartContract) UpdateOrganization(ctx contractapi.TransactionContextInterface, orgId, name string) error {
+    org, err := s.QueryOrganization(ctx, orgId)
     if err != nil {
-        return nil, fmt.Errorf("failed to read user: %v", err)
+        return err
     }
-    if userJSON == nil {
-        return nil, fmt.Errorf("user %s not found", username)
+    if err := s.requireOrgAdmin(ctx, org.MspID); err != nil {
+        return err
     }
-    var user User
-    if err := json.Unmarshal(userJSON, &user); err != nil {
-        return nil, err
+
+    org.Name = name
+    if err := putOrganization(ctx, org); err != nil {
+        return err
     }
-    return &user, nil
+    return emitEvent(ctx, EventOrganizationUpdated, OrganizationEventPayload{OrgID: orgId, MspID: org.MspID, TxID: ctx.GetStub().GetTxID()})
 }
 
-// --------------------------- CASES ---------------------------------
-
-func (s *SmartContract) CreateCase(ctx contractapi.TransactionContextInterface, id, title, description, jurisdiction, caseType, policyId string) error {
-    key := "case:" + id
-    exists, err := ctx.GetStub().GetState(key)
+// AddOrganizationMember adds an existing user of the organization to its Members list.
+// orgId may be the org ID or the MSP ID.
+func (s *SmartContract) AddOrganizationMember(ctx contractapi.TransactionContextInterface, orgId, username string) error {
+    org, err := s.resolveOrganization(ctx, orgId)
     if err != nil {
-        return fmt.Errorf("failed to check case: %v", err)
+        return err
     }
-    if exists != nil {
-        return fmt.Errorf("case %s already exists", id)
+    if err := s.requireOrgAdmin(ctx, org.MspID); err != nil {
+        return err
     }
 
-    // Verify policy exists and creator has access
-    if policyId != "" {
-        policy, err := s.QueryPolicy(ctx, policyId)
-        if err != nil {
-            return fmt.Errorf("failed to get policy %s: %v", policyId, err)
-        }
-        clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
-        if err != nil {
-            return fmt.Errorf("failed to get client MSP ID: %v", err)
-        }
-        
-        // Check if org is allowed by policy
-        orgAllowed := false
-        for _, o := range policy.AllowedOrgs {
-            if o == clientMSPID || o == "*" {
-                orgAllowed = true
-                break
-            }
-        }
-        if !orgAllowed {
-            return fmt.Errorf("organization %s not allowed by policy %s", clientMSPID, policyId)
+    user, err := s.QueryUser(ctx, username)
+    if err != nil {
+        return err
+    }
+    // Resolve the user's organization to an MSP ID, as requireOrgAdmin compares MSP IDs
+    userOrg, err := s.findOrganization(ctx, user.Organization)
+    if err != nil {
+        return err
+    }
+    if userOrg == nil || userOrg.MspID != org.MspID {
+        return fmt.Errorf("user %s belongs to %s, not %s", username, user.Organization, orgId)
+    }
+    for _, m := range org.Members {
+        if m == username {
+            return fmt.Errorf("user %s is already a member of %s", username, orgId)
         }
     }
 
-    clientMSPID, _ := ctx.GetClientIdentity().GetMSPID()
-
-    caseObj := Case{
-        DocType:     "case",
-        ID:          id,
-        Title:       title,
-        Description: description,
-        Status:      "Open",
-        Jurisdiction: jurisdiction,
-        CaseType:    caseType,
-        CreatedBy:   clientMSPID,
-        CreatedAt:   "auto-generated",
-        Organization: clientMSPID,
-        PolicyID:    policyId,
+    org.Members = append(org.Members, username)
+    if err := putOrganization(ctx, org); err != nil {
+        return err
     }
+    return emitEvent(ctx, EventOrgMemberAdded, OrganizationEventPayload{OrgID: org.OrgID, MspID: org.MspID, Member: username, TxID: ctx.GetStub().GetTxID()})
+}
 
-    caseJSON, err := json.Marshal(caseObj)
+// RemoveOrganizationMember removes a user from the organization's Members list.
+func (s *SmartContract) RemoveOrganizationMember(ctx contractapi.TransactionContextInterface, orgId, username string) error {
+    org, err := s.resolveOrganization(ctx, orgId)
     if err != nil {
         return err
     }
+    if err := s.requireOrgAdmin(ctx, org.MspID); err != nil {
+        return err
+    }
+
+    members := []string{}
+    for _, m := range org.Members {
+        if m != username {
+            members = append(members, m)
+        }
+    }
+    if len(members) == len(org.Members) {
+        return fmt.Errorf("user %s is not a member of %s", username, orgId)
+    }
 
-    return ctx.GetStub().PutState(key, caseJSON)
+    org.Members = members
+    if err := putOrganization(ctx, org); err != nil {
+        return err
+    }
+    return emitEvent(ctx, EventOrgMemberRemoved, OrganizationEventPayload{OrgID: org.OrgID, MspID: org.MspID, Member: username, TxID: ctx.GetStub().GetTxID()})
 }
 
-func (s *SmartContract) QueryCase(ctx contractapi.TransactionContextInterface, id string, userRole string) (*Case, error) {
-    key := "case:" + id
-    caseJSON, err := ctx.GetStub().GetState(key)
+// requireOrgAdmin checks that the caller is an admin submitting from the given MSP.
+func (s *SmartContract) requireOrgAdmin(ctx contractapi.TransactionContextInterface, mspId string) error {
+    c, err := s.getCaller(ctx)
     if err != nil {
-        return nil, fmt.Errorf("failed to read case: %v", err)
+        return err
     }
-    if caseJSON == nil {
-        return nil, fmt.Errorf("case %s not found", id)
+    if c.MSPID != mspId {
+        return fmt.Errorf("client MSP %s does not match organization MSP %s", c.MSPID, mspId)
     }
-    var caseObj Case
-    if err := json.Unmarshal(caseJSON, &caseObj); err != nil {
-        return nil, err
+    if !c.IsAdmin {
+        return fmt.Errorf("caller is not an admin of %s", mspId)
     }
+    return nil
+}
 
-    // If case has a policy, check access
-    if caseObj.PolicyID != "" {
-        // Get client org for policy check
-        clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
-        if err != nil {
-            return nil, fmt.Errorf("failed to get client MSP ID: %v", err)
-        }
+// resolveOrganization is findOrganization for transactions that require the organization to exist.
+func (s *SmartContract) resolveOrganization(ctx contractapi.TransactionContextInterface, ref string) (*Organization, error) {
+    org, err := s.findOrganization(ctx, ref)
+    if err != nil {
+        return nil, err
+    }
+    if org == nil {
+        return nil, fmt.Errorf("organization %s not found", ref)
+    }
+    return org, nil
+}
 
-        policy, err := s.QueryPolicy(ctx, caseObj.PolicyID)
+// organizationMSP returns the MSP ID of the organization ref names, by org ID or MSP ID. An unregistered
+// ref is taken to be an MSP ID already, as User.Organization usually is.
+func (s *SmartContract) organizationMSP(ctx contractapi.TransactionContextInterface, ref string) (string, error) {
+    org, err := s.findOrganization(ctx, ref)
+    if err != nil {
+        return "", err
+    }
+    if org == nil {
+        return ref, nil
+    }
+    return org.MspID, nil
+}
+
+// findOrganization looks an organization up by org ID, falling back to its MSP ID
+// (User.Organization holds the MSP ID). It returns nil if no organization matches.
+func (s *SmartContract) findOrganization(ctx contractapi.TransactionContextInterface, ref string) (*Organization, error) {
+    orgJSON, err := ctx.GetStub().GetState("org:" + ref)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read organization: %v", err)
+    }
+    if orgJSON != nil {
+        var org Organization
+        if err := json.Unmarshal(orgJSON, &org); err != nil {
+            return nil, err
+        }
+        return &org, nil
+    }
+
+    orgs, err := s.QueryAllOrganizations(ctx)
+    if err != nil {
+        return nil, err
+    }
+    for _, org := range orgs {
+        if org.MspID == ref {
+            return org, nil
+        }
+    }
+    return nil, nil
+}
+
+func putOrganization(ctx contractapi.TransactionContextInterface, org *Organization) error {
+    var err error
+    org.UpdatedAt, org.UpdatedBy, err = txAuthor(ctx)
+    if err != nil {
+        return err
+    }
+    orgJSON, err := json.Marshal(org)
+    if err != nil {
+        return err
+    }
+    return ctx.GetStub().PutState("org:"+org.OrgID, orgJSON)
+}
+
+// --------------------------- USERS ---------------------------------
+
+// CreateUser stores a user including a password hash.
+// passwordHash must be generated and provided by backend (bcrypt).
+// Only an admin of the organization may create its users; organization may be an org ID or an MSP ID.
+func (s *SmartContract) CreateUser(ctx contractapi.TransactionContextInterface, username, fullName, email, role, organization, passwordHash string) error {
+    key := "user:" + username
+    exists, err := ctx.GetStub().GetState(key)
+    if err != nil {
+        return fmt.Errorf("failed to check user: %v", err)
+    }
+    if exists != nil {
+        return fmt.Errorf("user %s already exists", username)
+    }
+
+    org, err := s.findOrganization(ctx, organization)
+    if err != nil {
+        return err
+    }
+    // An unregistered organization is taken to be an MSP ID, so the caller must still submit from it
+    mspId := organization
+    if org != nil {
+        mspId = org.MspID
+    }
+    if err := s.requireOrgAdmin(ctx, mspId); err != nil {
+        return err
+    }
+
+    now, clientID, err := txAuthor(ctx)
+    if err != nil {
+        return err
+    }
+
+    user := User{
+        DocType:      "user",
+        Username:     username,
+        FullName:     fullName,
+        Email:        email,
+        Role:         role,
+        Organization: organization,
+        PasswordHash: passwordHash,
+        CreatedAt:    now,
+        UpdatedAt:    now,
+        UpdatedBy:    clientID,
+        Status:       UserStatusActive,
+    }
+
+    userJSON, err := json.Marshal(user)
+    if err != nil {
+        return err
+    }
+
+    if err := ctx.GetStub().PutState(key, userJSON); err != nil {
+        return err
+    }
+
+    // Keep the organization's member list in sync
+    if org != nil {
+        listed := false
+        for _, m := range org.Members {
+            if m == username {
+                listed = true
+                break
+            }
+        }
+        if !listed {
+            org.Members = append(org.Members, username)
+            if err := putOrganization(ctx, org); err != nil {
+                return err
+            }
+        }
+    } else {
+        log.Printf("Warning: organization %s is not registered; user %s was not added to a member list", organization, username)
+    }
+
+    return emitEvent(ctx, EventUserCreated, UserEventPayload{
+        Username:     username,
+        Role:         role,
+        Organization: organization,
+        TxID:         ctx.GetStub().GetTxID(),
+    })
+}
+
+// QueryUser returns a user (including PasswordHash for auth verification by backend).
+func (s *SmartContract) QueryUser(ctx contractapi.TransactionContextInterface, username string) (*User, error) {
+    key := "user:" + username
+    userJSON, err := ctx.GetStub().GetState(key)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read user: %v", err)
+    }
+    if userJSON == nil {
+        return nil, fmt.Errorf("user %s not found", username)
+    }
+    var user User
+    if err := json.Unmarshal(userJSON, &user); err != nil {
+        return nil, err
+    }
+    return &user, nil
+}
+
+// UpdateUserRole changes a user's role. Only an admin of the user's organization may do this.
+func (s *SmartContract) UpdateUserRole(ctx contractapi.TransactionContextInterface, username, role string) error {
+    if role == "" {
+        return fmt.Errorf("role is required")
+    }
+    user, err := s.QueryUser(ctx, username)
+    if err != nil {
+        return err
+    }
+    if err := s.requireUserAdmin(ctx, user); err != nil {
+        return err
+    }
+
+    user.Role = role
+    return s.putUserWithEvent(ctx, user, EventUserRoleUpdated)
+}
+
+// SuspendUser blocks a user: every access-checked transaction submitted with the user's bound
+// identity is rejected until ReactivateUser is called.
+func (s *SmartContract) SuspendUser(ctx contractapi.TransactionContextInterface, username, reason string) error {
+    user, err := s.QueryUser(ctx, username)
+    if err != nil {
+        return err
+    }
+    if err := s.requireUserAdmin(ctx, user); err != nil {
+        return err
+    }
+    if user.Status == UserStatusSuspended {
+        return fmt.Errorf("user %s is already suspended", username)
+    }
+
+    user.Status = UserStatusSuspended
+    user.StatusReason = reason
+    return s.putUserWithEvent(ctx, user, EventUserSuspended)
+}
+
+// ReactivateUser lifts a suspension.
+func (s *SmartContract) ReactivateUser(ctx contractapi.TransactionContextInterface, username string) error {
+    user, err := s.QueryUser(ctx, username)
+    if err != nil {
+        return err
+    }
+    if err := s.requireUserAdmin(ctx, user); err != nil {
+        return err
+    }
+    if user.Status != UserStatusSuspended {
+        return fmt.Errorf("user %s is not suspended", username)
+    }
+
+    user.Status = UserStatusActive
+    user.StatusReason = ""
+    return s.putUserWithEvent(ctx, user, EventUserReactivated)
+}
+
+// QueryUsersByOrganization returns the users of an organization. organization and each User.Organization
+// may be an org ID or an MSP ID; they match when they resolve to the same MSP. Password hashes are not included.
+func (s *SmartContract) QueryUsersByOrganization(ctx contractapi.TransactionContextInterface, organization string) ([]*User, error) {
+    mspId, err := s.organizationMSP(ctx, organization)
+    if err != nil {
+        return nil, err
+    }
+    userMSPs := map[string]string{}
+
+    resultsIterator, err := ctx.GetStub().GetStateByRange("user:", "user:\uffff")
+    if err != nil {
+        return nil, fmt.Errorf("failed to execute user query: %v", err)
+    }
+    defer resultsIterator.Close()
+
+    var users []*User
+    for resultsIterator.HasNext() {
+        qr, err := resultsIterator.Next()
+        if err != nil {
+            return nil, err
+        }
+        var u User
+        if err := json.Unmarshal(qr.Value, &u); err != nil {
+            return nil, err
+        }
+        if u.DocType != "user" {
+            continue
+        }
+        userMSP, ok := userMSPs[u.Organization]
+        if !ok {
+            if userMSP, err = s.organizationMSP(ctx, u.Organization); err != nil {
+                return nil, err
+            }
+            userMSPs[u.Organization] = userMSP
+        }
+        if userMSP == mspId {
+            u.PasswordHash = ""
+            if u.Status == "" {
+                u.Status = UserStatusActive
+            }
+            users = append(users, &u)
+        }
+    }
+    return users, nil
+}
+
+// requireUserAdmin checks that the caller is an admin of the user's organization, resolving an org ID
+// in User.Organization to its MSP ID.
+func (s *SmartContract) requireUserAdmin(ctx contractapi.TransactionContextInterface, user *User) error {
+    mspId, err := s.organizationMSP(ctx, user.Organization)
+    if err != nil {
+        return err
+    }
+    return s.requireOrgAdmin(ctx, mspId)
+}
+
+func (s *SmartContract) putUserWithEvent(ctx contractapi.TransactionContextInterface, user *User, eventName string) error {
+    var err error
+    user.UpdatedAt, user.UpdatedBy, err = txAuthor(ctx)
+    if err != nil {
+        return err
+    }
+    userJSON, err := json.Marshal(user)
+    if err != nil {
+        return err
+    }
+    if err := ctx.GetStub().PutState("user:"+user.Username, userJSON); err != nil {
+        return err
+    }
+    return emitEvent(ctx, eventName, UserEventPayload{
+        Username:     user.Username,
+        Role:         user.Role,
+        Organization: user.Organization,
+        Status:       user.Status,
+        TxID:         ctx.GetStub().GetTxID(),
+    })
+}
+
+// --------------------------- IDENTITY ------------------------------
+// Roles are never taken from transaction arguments. A caller's role comes from the "role" attribute of
+// its X.509 certificate (set at enrollment by the Fabric CA) or, failing that, from the on-ledger User
+// bound to the certificate ID with BindUserIdentity.
+
+// adminRole is the role that may administer an organization's users and data.
+const adminRole = "admin"
+
+// judgeRole may place and release legal holds on cases it can access.
+const judgeRole = "judge"
+
+// userCertIndex maps a certificate ID (ClientIdentity.GetID) to the username bound to it.
+const userCertIndex = "user~cert"
+
+// caller describes the identity submitting the current transaction.
+type caller struct {
+    ID       string
+    MSPID    string
+    Role     string // empty when neither the certificate nor a bound user provides one
+    Username string // empty when no ledger user is bound to the certificate
+    IsAdmin  bool   // admin role, or an admin certificate (NodeOU "admin")
+}
+
+// getCaller resolves the submitting identity and its role. It fails for suspended users, so every
+// transaction that checks access through it rejects them.
+func (s *SmartContract) getCaller(ctx contractapi.TransactionContextInterface) (*caller, error) {
+    clientID, err := ctx.GetClientIdentity().GetID()
+    if err != nil {
+        return nil, fmt.Errorf("failed to get client ID: %v", err)
+    }
+    clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+    if err != nil {
+        return nil, fmt.Errorf("failed to get client MSP ID: %v", err)
+    }
+    c := &caller{ID: clientID, MSPID: clientMSPID}
+
+    role, found, err := ctx.GetClientIdentity().GetAttributeValue("role")
+    if err != nil {
+        return nil, fmt.Errorf("failed to read role attribute: %v", err)
+    }
+    if found {
+        c.Role = role
+    }
+
+    indexKey, err := ctx.GetStub().CreateCompositeKey(userCertIndex, []string{clientID})
+    if err != nil {
+        return nil, fmt.Errorf("failed to create %s index key: %v", userCertIndex, err)
+    }
+    username, err := ctx.GetStub().GetState(indexKey)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read %s index: %v", userCertIndex, err)
+    }
+    if username != nil {
+        user, err := s.QueryUser(ctx, string(username))
+        if err != nil {
+            return nil, err
+        }
+        if user.Status == UserStatusSuspended {
+            return nil, fmt.Errorf("user %s is suspended", user.Username)
+        }
+        c.Username = user.Username
+        if c.Role == "" {
+            c.Role = user.Role
+        }
+    }
+
+    c.IsAdmin = c.Role == adminRole
+    if cert, err := ctx.GetClientIdentity().GetX509Certificate(); err == nil && cert != nil {
+        for _, ou := range cert.Subject.OrganizationalUnit {
+            if ou == adminRole {
+                c.IsAdmin = true
+                break
+            }
+        }
+    }
+    return c, nil
+}
+
+// BindUserIdentity binds a certificate ID (as returned by ClientIdentity.GetID) to a ledger user, so
+// transactions submitted with that certificate carry the user's role. Only an admin of the user's
+// organization may bind identities.
+func (s *SmartContract) BindUserIdentity(ctx contractapi.TransactionContextInterface, username, certId string) error {
+    if certId == "" {
+        return fmt.Errorf("certificate ID is required")
+    }
+    user, err := s.QueryUser(ctx, username)
+    if err != nil {
+        return err
+    }
+
+    if err := s.requireUserAdmin(ctx, user); err != nil {
+        return fmt.Errorf("only an admin of %s may bind identities for user %s: %v", user.Organization, username, err)
+    }
+
+    newKey, err := ctx.GetStub().CreateCompositeKey(userCertIndex, []string{certId})
+    if err != nil {
+        return fmt.Errorf("failed to create %s index key: %v", userCertIndex, err)
+    }
+    boundTo, err := ctx.GetStub().GetState(newKey)
+    if err != nil {
+        return fmt.Errorf("failed to read %s index: %v", userCertIndex, err)
+    }
+    if boundTo != nil && string(boundTo) != username {
+        return fmt.Errorf("certificate is already bound to user %s", string(boundTo))
+    }
+
+    // A user has at most one bound certificate
+    if user.CertID != "" && user.CertID != certId {
+        oldKey, err := ctx.GetStub().CreateCompositeKey(userCertIndex, []string{user.CertID})
+        if err != nil {
+            return fmt.Errorf("failed to create %s index key: %v", userCertIndex, err)
+        }
+        if err := ctx.GetStub().DelState(oldKey); err != nil {
+            return err
+        }
+    }
+
+    if err := ctx.GetStub().PutState(newKey, []byte(username)); err != nil {
+        return err
+    }
+    user.CertID = certId
+    return s.putUserWithEvent(ctx, user, EventUserIdentityBound)
+}
+
+// --------------------------- CASES ---------------------------------
+
+// CreateCase creates a case owned by the submitting organization. The description is stored in the
+// case details collection; pass it in the transient map under "description".
+func (s *SmartContract) CreateCase(ctx contractapi.TransactionContextInterface, id, title, description, jurisdiction, caseType, policyId string) error {
+    key := "case:" + id
+    exists, err := ctx.GetStub().GetState(key)
+    if err != nil {
+        return fmt.Errorf("failed to check case: %v", err)
+    }
+    if exists != nil {
+        return fmt.Errorf("case %s already exists", id)
+    }
+    // Resolving the caller refuses suspended users
+    if _, err := s.getCaller(ctx); err != nil {
+        return err
+    }
+
+    // Verify policy exists and creator has access
+    if policyId != "" {
+        policy, err := s.QueryPolicy(ctx, policyId)
+        if err != nil {
+            return fmt.Errorf("failed to get policy %s: %v", policyId, err)
+        }
+        clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+        if err != nil {
+            return fmt.Errorf("failed to get client MSP ID: %v", err)
+        }
+        
+        // Check if org is allowed by policy
+        if !policyAllowsOrg(policy, clientMSPID) {
+            return fmt.Errorf("organization %s not allowed by policy %s", clientMSPID, policyId)
+        }
+    }
+
+    clientMSPID, _ := ctx.GetClientIdentity().GetMSPID()
+    now, clientID, err := txAuthor(ctx)
+    if err != nil {
+        return err
+    }
+
+    description, err = privateDescription(ctx, description)
+    if err != nil {
+        return err
+    }
+    descriptionHash, err := putPrivateDescription(ctx, caseDetailsCollection, key, id, description)
+    if err != nil {
+        return err
+    }
+
+    caseObj := Case{
+        DocType:     "case",
+        ID:          id,
+        Title:       title,
+        DescriptionHash: descriptionHash,
+        Status:      CaseStatusOpen,
+        Jurisdiction: jurisdiction,
+        CaseType:    caseType,
+        CreatedBy:   clientMSPID,
+        CreatedAt:   now,
+        UpdatedAt:   now,
+        UpdatedBy:   clientID,
+        Organization: clientMSPID,
+        PolicyID:    policyId,
+    }
+
+    caseJSON, err := json.Marshal(caseObj)
+    if err != nil {
+        return err
+    }
+
+    if err := ctx.GetStub().PutState(key, caseJSON); err != nil {
+        return err
+    }
+    return emitEvent(ctx, EventCaseCreated, CaseEventPayload{
+        CaseID:       id,
+        Status:       caseObj.Status,
+        Organization: caseObj.Organization,
+        PolicyID:     policyId,
+        TxID:         ctx.GetStub().GetTxID(),
+    })
+}
+
+// QueryCase returns a case if the caller passes its policy. The caller's role comes from its identity
+// (see getCaller), never from the client.
+func (s *SmartContract) QueryCase(ctx contractapi.TransactionContextInterface, id string) (*Case, error) {
+    caseObj, err := s.readCase(ctx, id)
+    if err != nil {
+        return nil, err
+    }
+    if err := s.checkCaseAccess(ctx, caseObj); err != nil {
+        return nil, err
+    }
+    caseObj.Description = getPrivateDescription(ctx, caseDetailsCollection, "case:"+id)
+    return caseObj, nil
+}
+
+// checkCaseAccess applies the case's policy to the caller. Cases without a policy are open to everyone.
+func (s *SmartContract) checkCaseAccess(ctx contractapi.TransactionContextInterface, caseObj *Case) error {
+    // Resolve the caller first so suspended users are refused even on cases without a policy
+    c, err := s.getCaller(ctx)
+    if err != nil {
+        return err
+    }
+    if caseObj.PolicyID == "" {
+        return nil
+    }
+
+    policy, err := s.QueryPolicy(ctx, caseObj.PolicyID)
+    if err != nil {
+        return fmt.Errorf("failed to get policy %s: %v", caseObj.PolicyID, err)
+    }
+
+    return evaluateAccess(ctx, policy, caseGrantIndex, caseObj.ID, c)
+}
+
+// readCase loads a case from world state without any policy check.
+func (s *SmartContract) readCase(ctx contractapi.TransactionContextInterface, id string) (*Case, error) {
+    caseJSON, err := ctx.GetStub().GetState("case:" + id)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read case: %v", err)
+    }
+    if caseJSON == nil {
+        return nil, fmt.Errorf("case %s not found", id)
+    }
+    var caseObj Case
+    if err := json.Unmarshal(caseJSON, &caseObj); err != nil {
+        return nil, err
+    }
+    return &caseObj, nil
+}
+
+// UpdateCaseStatus moves a case to newStatus. Only transitions listed in caseStatusTransitions are allowed;
+// reopening a closed case requires ReopenCase. The reason and the submitting identity are kept in StatusHistory.
+func (s *SmartContract) UpdateCaseStatus(ctx contractapi.TransactionContextInterface, id, newStatus, reason string) error {
+    caseObj, err := s.readCase(ctx, id)
+    if err != nil {
+        return err
+    }
+
+    if _, known := caseStatusTransitions[newStatus]; !known {
+        return fmt.Errorf("invalid case status %q", newStatus)
+    }
+    if caseObj.Status == newStatus {
+        return fmt.Errorf("case %s is already %s", id, newStatus)
+    }
+    if caseObj.Status == CaseStatusClosed && newStatus == CaseStatusOpen {
+        return fmt.Errorf("case %s is closed; use ReopenCase to reopen it", id)
+    }
+
+    allowed := false
+    for _, next := range caseStatusTransitions[caseObj.Status] {
+        if next == newStatus {
+            allowed = true
+            break
+        }
+    }
+    if !allowed {
+        return fmt.Errorf("illegal status transition for case %s: %s -> %s", id, caseObj.Status, newStatus)
+    }
+
+    return s.changeCaseStatus(ctx, caseObj, newStatus, reason)
+}
+
+// ReopenCase moves a closed case back to Open.
+func (s *SmartContract) ReopenCase(ctx contractapi.TransactionContextInterface, id, reason string) error {
+    caseObj, err := s.readCase(ctx, id)
+    if err != nil {
+        return err
+    }
+    if caseObj.Status != CaseStatusClosed {
+        return fmt.Errorf("case %s is %s; only closed cases can be reopened", id, caseObj.Status)
+    }
+    return s.changeCaseStatus(ctx, caseObj, CaseStatusOpen, reason)
+}
+
+// changeCaseStatus applies an already validated status transition and appends it to the case history.
+func (s *SmartContract) changeCaseStatus(ctx contractapi.TransactionContextInterface, caseObj *Case, newStatus, reason string) error {
+    if reason == "" {
+        return fmt.Errorf("a reason is required to change the status of case %s", caseObj.ID)
+    }
+
+    c, err := s.getCaller(ctx)
+    if err != nil {
+        return err
+    }
+    if c.MSPID != caseObj.Organization {
+        return fmt.Errorf("organization %s cannot change the status of case %s owned by %s", c.MSPID, caseObj.ID, caseObj.Organization)
+    }
+    now, err := txTimestamp(ctx)
+    if err != nil {
+        return err
+    }
+
+    caseObj.UpdatedAt = now
+    caseObj.UpdatedBy = c.ID
+    caseObj.StatusHistory = append(caseObj.StatusHistory, CaseStatusChange{
+        From:         caseObj.Status,
+        To:           newStatus,
+        Reason:       reason,
+        ChangedBy:    c.ID,
+        ChangedByMSP: c.MSPID,
+        ChangedAt:    now,
+        TxID:         ctx.GetStub().GetTxID(),
+    })
+    caseObj.Status = newStatus
+
+    caseJSON, err := json.Marshal(caseObj)
+    if err != nil {
+        return err
+    }
+    if err := ctx.GetStub().PutState("case:"+caseObj.ID, caseJSON); err != nil {
+        return err
+    }
+    return emitEvent(ctx, EventCaseStatusChanged, CaseEventPayload{
+        CaseID:       caseObj.ID,
+        Status:       newStatus,
+        Organization: caseObj.Organization,
+        PolicyID:     caseObj.PolicyID,
+        TxID:         ctx.GetStub().GetTxID(),
+    })
+}
+
+// QueryAllCases returns the cases the caller may access that match the optional filters JSON
+// (see FILTERS), e.g. {"status": "Open", "jurisdiction": {"$in": ["North", "East"]}}.
+// If filters is empty, returns all accessible cases.
+func (s *SmartContract) QueryAllCases(ctx contractapi.TransactionContextInterface, filters string) ([]*Case, error) {
+    caseFilters, err := parseFilters(filters)
+    if err != nil {
+        return nil, err
+    }
+
+    startKey := "case:"
+    endKey := "case:\uffff"
+
+    resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, endKey)
+    if err != nil {
+        return nil, fmt.Errorf("failed to execute case query: %v", err)
+    }
+    defer resultsIterator.Close()
+
+    c, err := s.getCaller(ctx)
+    if err != nil {
+        return nil, err
+    }
+
+    return s.collectCases(ctx, resultsIterator, c, caseFilters)
+}
+
+// QueryAllCasesWithPagination is the paginated form of QueryAllCases. Cases the caller may not see or that
+// do not match the filters are dropped from the page, so a page can be short while the bookmark is non-empty.
+func (s *SmartContract) QueryAllCasesWithPagination(ctx contractapi.TransactionContextInterface, filters string, pageSize int32, bookmark string) (*PaginatedCases, error) {
+    if err := validatePageSize(pageSize); err != nil {
+        return nil, err
+    }
+    caseFilters, err := parseFilters(filters)
+    if err != nil {
+        return nil, err
+    }
+
+    resultsIterator, metadata, err := ctx.GetStub().GetStateByRangeWithPagination("case:", "case:\uffff", pageSize, bookmark)
+    if err != nil {
+        return nil, fmt.Errorf("failed to execute case query: %v", err)
+    }
+    defer resultsIterator.Close()
+
+    c, err := s.getCaller(ctx)
+    if err != nil {
+        return nil, err
+    }
+
+    cases, err := s.collectCases(ctx, resultsIterator, c, caseFilters)
+    if err != nil {
+        return nil, err
+    }
+    return &PaginatedCases{Results: cases, Bookmark: metadata.Bookmark, FetchedCount: metadata.FetchedRecordsCount}, nil
+}
+
+// collectCases reads cases from a case: range iterator, keeping those the caller may access that match the filters.
+func (s *SmartContract) collectCases(ctx contractapi.TransactionContextInterface, resultsIterator shim.StateQueryIteratorInterface, caller *caller, filters map[string]interface{}) ([]*Case, error) {
+    var cases []*Case
+    for resultsIterator.HasNext() {
+        qr, err := resultsIterator.Next()
+        if err != nil {
+            return nil, err
+        }
+        var c Case
+        if err := json.Unmarshal(qr.Value, &c); err != nil {
+            return nil, err
+        }
+
+        if c.DocType != "case" || !s.caseAccessible(ctx, &c, caller) {
+            continue
+        }
+
+        // Filters are only evaluated once the policy check has passed
+        if len(filters) > 0 {
+            doc, err := toDocument(&c)
+            if err != nil {
+                return nil, err
+            }
+            matched, err := matchesFilters(doc, filters)
+            if err != nil {
+                return nil, err
+            }
+            if !matched {
+                continue
+            }
+        }
+        cases = append(cases, &c)
+    }
+    return cases, nil
+}
+
+// caseAccessible reports whether the case may be listed for the caller, using the same decision as QueryCase.
+// Cases without a policy are visible to everyone.
+func (s *SmartContract) caseAccessible(ctx contractapi.TransactionContextInterface, c *Case, caller *caller) bool {
+    if c.PolicyID == "" {
+        return true
+    }
+
+    // Check policy access
+    policy, err := s.QueryPolicy(ctx, c.PolicyID)
+    if err != nil {
+        log.Printf("Warning: Could not check policy %s for case %s: %v", c.PolicyID, c.ID, err)
+        return false
+    }
+
+    return evaluateAccess(ctx, policy, caseGrantIndex, c.ID, caller) == nil
+}
+
+// --------------------------- CASE LINKS -----------------------------
+// A link between two cases is stored twice under link~case composite keys, as [caseA, caseB] with the
+// relation and as [caseB, caseA] with its inverse, so either case can list its links by partial key.
+
+const caseLinkIndex = "link~case"
+
+// LinkedCase is one result of QueryLinkedCases.
+type LinkedCase struct {
+    Link *CaseLink `json:"link"`
+    Case *Case     `json:"case"`
+}
+
+// LinkCases records that caseA <relationType> caseB, e.g. LinkCases("c1", "c2", "parent-of"), along with
+// the inverse link from caseB. The caller must be able to access both cases and belong to the
+// organization owning at least one of them. A pair of cases can only be linked once.
+func (s *SmartContract) LinkCases(ctx contractapi.TransactionContextInterface, caseA, caseB, relationType string) error {
+    inverse, ok := caseLinkInverses[relationType]
+    if !ok {
+        return fmt.Errorf("invalid relation type %s", relationType)
+    }
+    c, err := s.checkCaseLinkAccess(ctx, caseA, caseB)
+    if err != nil {
+        return err
+    }
+
+    existingKey, err := ctx.GetStub().CreateCompositeKey(caseLinkIndex, []string{caseA, caseB})
+    if err != nil {
+        return fmt.Errorf("failed to create link key: %v", err)
+    }
+    existing, err := ctx.GetStub().GetState(existingKey)
+    if err != nil {
+        return fmt.Errorf("failed to read case link: %v", err)
+    }
+    if existing != nil {
+        return fmt.Errorf("cases %s and %s are already linked; unlink them first", caseA, caseB)
+    }
+
+    now, err := txTimestamp(ctx)
+    if err != nil {
+        return err
+    }
+    link := CaseLink{
+        CaseID:       caseA,
+        LinkedCaseID: caseB,
+        RelationType: relationType,
+        LinkedBy:     c.ID,
+        LinkedByMSP:  c.MSPID,
+        LinkedAt:     now,
+        TxID:         ctx.GetStub().GetTxID(),
+    }
+    if err := putCaseLink(ctx, &link); err != nil {
+        return err
+    }
+    reverse := link
+    reverse.CaseID, reverse.LinkedCaseID, reverse.RelationType = caseB, caseA, inverse
+    if err := putCaseLink(ctx, &reverse); err != nil {
+        return err
+    }
+    return emitEvent(ctx, EventCasesLinked, CaseLinkEventPayload{
+        CaseID:       caseA,
+        LinkedCaseID: caseB,
+        RelationType: relationType,
+        TxID:         ctx.GetStub().GetTxID(),
+    })
+}
+
+// UnlinkCases removes the link between two cases in both directions. The same access rules as LinkCases apply.
+func (s *SmartContract) UnlinkCases(ctx contractapi.TransactionContextInterface, caseA, caseB string) error {
+    if _, err := s.checkCaseLinkAccess(ctx, caseA, caseB); err != nil {
+        return err
+    }
+
+    linkKey, err := ctx.GetStub().CreateCompositeKey(caseLinkIndex, []string{caseA, caseB})
+    if err != nil {
+        return fmt.Errorf("failed to create link key: %v", err)
+    }
+    linkJSON, err := ctx.GetStub().GetState(linkKey)
+    if err != nil {
+        return fmt.Errorf("failed to read case link: %v", err)
+    }
+    if linkJSON == nil {
+        return fmt.Errorf("cases %s and %s are not linked", caseA, caseB)
+    }
+    var link CaseLink
+    if err := json.Unmarshal(linkJSON, &link); err != nil {
+        return err
+    }
+    reverseKey, err := ctx.GetStub().CreateCompositeKey(caseLinkIndex, []string{caseB, caseA})
+    if err != nil {
+        return fmt.Errorf("failed to create link key: %v", err)
+    }
+    if err := ctx.GetStub().DelState(linkKey); err != nil {
+        return err
+    }
+    if err := ctx.GetStub().DelState(reverseKey); err != nil {
+        return err
+    }
+    return emitEvent(ctx, EventCasesUnlinked, CaseLinkEventPayload{
+        CaseID:       caseA,
+        LinkedCaseID: caseB,
+        RelationType: link.RelationType,
+        TxID:         ctx.GetStub().GetTxID(),
+    })
+}
+
+// QueryLinkedCases returns the cases linked to caseId, each with the relation as seen from caseId.
+// Linked cases the caller may not list (see caseAccessible) are left out, as are links to deleted cases.
+func (s *SmartContract) QueryLinkedCases(ctx contractapi.TransactionContextInterface, caseId string) ([]*LinkedCase, error) {
+    caseObj, err := s.readCase(ctx, caseId)
+    if err != nil {
+        return nil, err
+    }
+    if err := s.checkCaseAccess(ctx, caseObj); err != nil {
+        return nil, err
+    }
+    c, err := s.getCaller(ctx)
+    if err != nil {
+        return nil, err
+    }
+
+    resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(caseLinkIndex, []string{caseId})
+    if err != nil {
+        return nil, fmt.Errorf("failed to query case links: %v", err)
+    }
+    defer resultsIterator.Close()
+
+    var linked []*LinkedCase
+    for resultsIterator.HasNext() {
+        qr, err := resultsIterator.Next()
+        if err != nil {
+            return nil, err
+        }
+        var link CaseLink
+        if err := json.Unmarshal(qr.Value, &link); err != nil {
+            return nil, err
+        }
+        linkedJSON, err := ctx.GetStub().GetState("case:" + link.LinkedCaseID)
+        if err != nil {
+            return nil, fmt.Errorf("failed to read case %s: %v", link.LinkedCaseID, err)
+        }
+        if linkedJSON == nil {
+            continue // Linked case was deleted
+        }
+        var other Case
+        if err := json.Unmarshal(linkedJSON, &other); err != nil {
+            return nil, err
+        }
+        if !s.caseAccessible(ctx, &other, c) {
+            continue
+        }
+        linked = append(linked, &LinkedCase{Link: &link, Case: &other})
+    }
+    return linked, nil
+}
+
+// checkCaseLinkAccess lets the caller manage links between two distinct cases it can access,
+// as long as its organization owns one of them.
+func (s *SmartContract) checkCaseLinkAccess(ctx contractapi.TransactionContextInterface, caseA, caseB string) (*caller, error) {
+    if caseA == caseB {
+        return nil, fmt.Errorf("a case cannot be linked to itself")
+    }
+    a, err := s.readCase(ctx, caseA)
+    if err != nil {
+        return nil, err
+    }
+    b, err := s.readCase(ctx, caseB)
+    if err != nil {
+        return nil, err
+    }
+    if err := s.checkCaseAccess(ctx, a); err != nil {
+        return nil, err
+    }
+    if err := s.checkCaseAccess(ctx, b); err != nil {
+        return nil, err
+    }
+
+    c, err := s.getCaller(ctx)
+    if err != nil {
+        return nil, err
+    }
+    if c.MSPID != a.Organization && c.MSPID != b.Organization {
+        return nil, fmt.Errorf("organization %s owns neither case %s nor case %s", c.MSPID, caseA, caseB)
+    }
+    return c, nil
+}
+
+func putCaseLink(ctx contractapi.TransactionContextInterface, link *CaseLink) error {
+    linkKey, err := ctx.GetStub().CreateCompositeKey(caseLinkIndex, []string{link.CaseID, link.LinkedCaseID})
+    if err != nil {
+        return fmt.Errorf("failed to create link key: %v", err)
+    }
+    linkJSON, err := json.Marshal(link)
+    if err != nil {
+        return err
+    }
+    return ctx.GetStub().PutState(linkKey, linkJSON)
+}
+
+// --------------------------- RECORDS --------------------------------
+
+// recordCaseIndex is the composite-key index mapping caseId -> recordId.
+// Entries are written by CreateRecord and UpdateRecordMetadata.
+const recordCaseIndex = "record~case"
+
+func putRecordCaseIndex(ctx contractapi.TransactionContextInterface, caseId, recordId string) error {
+    indexKey, err := ctx.GetStub().CreateCompositeKey(recordCaseIndex, []string{caseId, recordId})
+    if err != nil {
+        return fmt.Errorf("failed to create %s index key: %v", recordCaseIndex, err)
+    }
+    // Only the key matters; the value must be non-empty for PutState
+    return ctx.GetStub().PutState(indexKey, []byte{0x00})
+}
+
+// CreateRecord stores a Record. Backend should supply ownerOrg; createdAt is ignored and the transaction
+// timestamp is used instead. The description is stored in the record details collection; pass it in the
+// transient map under "description".
+func (s *SmartContract) CreateRecord(ctx contractapi.TransactionContextInterface, id, caseId, recordType, fileHash, offChainUri, ownerOrg, createdAt, policyId, description string) error {
+    key := "record:" + id
+    exists, err := ctx.GetStub().GetState(key)
+    if err != nil {
+        return fmt.Errorf("failed to check record: %v", err)
+    }
+    if exists != nil {
+        return fmt.Errorf("record %s already exists", id)
+    }
+    // Resolving the caller refuses suspended users
+    c, err := s.getCaller(ctx)
+    if err != nil {
+        return err
+    }
+    // A record for another (or a made-up) organization could never be retired by its creator
+    if ownerOrg != c.MSPID {
+        return fmt.Errorf("ownerOrg %s must be the caller's organization %s", ownerOrg, c.MSPID)
+    }
+    if err := s.requireCaseOpenForRecords(ctx, caseId); err != nil {
+        return err
+    }
+
+    if policyId != "" {
+        policy, err := s.QueryPolicy(ctx, policyId)
+        if err != nil {
+            return fmt.Errorf("failed to get policy %s: %v", policyId, err)
+        }
+        if !policyAllowsCategory(policy, recordType) {
+            return fmt.Errorf("record type %s is not in the categories of policy %s", recordType, policyId)
+        }
+    }
+
+    description, err = privateDescription(ctx, description)
+    if err != nil {
+        return err
+    }
+    descriptionHash, err := putPrivateDescription(ctx, recordDetailsCollection, key, id, description)
+    if err != nil {
+        return err
+    }
+
+    now, clientID, err := txAuthor(ctx)
+    if err != nil {
+        return err
+    }
+    clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+    if err != nil {
+        return fmt.Errorf("failed to get client MSP ID: %v", err)
+    }
+
+    rec := Record{
+        DocType:     "record",
+        ID:          id,
+        CaseID:      caseId,
+        RecordType:  recordType,
+        FileHash:    fileHash,
+        OffChainURI: offChainUri,
+        OwnerOrg:    ownerOrg,
+        CreatedAt:   now,
+        UpdatedAt:   now,
+        UpdatedBy:   clientID,
+        PolicyID:    policyId,
+        DescriptionHash: descriptionHash,
+        Status:      RecordStatusActive,
+        Version:     1,
+        HashAlgorithm: defaultHashAlgorithm,
+    }
+
+    recJSON, err := json.Marshal(rec)
+    if err != nil {
+        return err
+    }
+    if err := ctx.GetStub().PutState(key, recJSON); err != nil {
+        return err
+    }
+    if err := putRecordCaseIndex(ctx, caseId, id); err != nil {
+        return err
+    }
+    if err := putRecordVersion(ctx, &RecordVersion{
+        RecordID:    id,
+        Version:     1,
+        FileHash:    fileHash,
+        OffChainURI: offChainUri,
+        AddedBy:     clientID,
+        AddedByMSP:  clientMSPID,
+        AddedAt:     now,
+        TxID:        ctx.GetStub().GetTxID(),
+    }); err != nil {
+        return err
+    }
+    if err := appendCustodyEvent(ctx, id, "", ownerOrg, "record created"); err != nil {
+        return err
+    }
+    return emitEvent(ctx, EventRecordCreated, recordEventPayload(ctx, &rec, nil))
+}
+
+// QueryRecord returns a record if the caller passes its policy, with the role taken from its identity as in QueryCase.
+func (s *SmartContract) QueryRecord(ctx contractapi.TransactionContextInterface, id string) (*Record, error) {
+    rec, err := s.readRecord(ctx, id)
+    if err != nil {
+        return nil, err
+    }
+    if err := s.checkRecordAccess(ctx, rec); err != nil {
+        return nil, err
+    }
+    rec.Description = getPrivateDescription(ctx, recordDetailsCollection, "record:"+id)
+    return rec, nil
+}
+
+// readRecord loads a record from world state without any policy check.
+func (s *SmartContract) readRecord(ctx contractapi.TransactionContextInterface, id string) (*Record, error) {
+    recJSON, err := ctx.GetStub().GetState("record:" + id)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read record: %v", err)
+    }
+    if recJSON == nil {
+        return nil, fmt.Errorf("record %s not found", id)
+    }
+    var rec Record
+    if err := json.Unmarshal(recJSON, &rec); err != nil {
+        return nil, err
+    }
+    if rec.Version == 0 {
+        rec.Version = 1 // Created before record versioning
+    }
+    return &rec, nil
+}
+
+// checkRecordAccess applies the record's policy to the caller. Records without a policy are not accessible.
+func (s *SmartContract) checkRecordAccess(ctx contractapi.TransactionContextInterface, rec *Record) error {
+    c, err := s.getCaller(ctx)
+    if err != nil {
+        return err
+    }
+
+    // Get the policy
+    if rec.PolicyID == "" {
+        return fmt.Errorf("record %s has no associated policy", rec.ID)
+    }
+
+    policy, err := s.QueryPolicy(ctx, rec.PolicyID)
+    if err != nil {
+        return fmt.Errorf("failed to get policy %s: %v", rec.PolicyID, err)
+    }
+
+    return evaluateAccess(ctx, policy, recordGrantIndex, rec.ID, c)
+}
+
+// QueryRecordsByCase returns records belonging to a case
+func (s *SmartContract) QueryRecordsByCase(ctx contractapi.TransactionContextInterface, caseId string) ([]*Record, error) {
+    // Walk the record~case index instead of scanning every record
+    resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(recordCaseIndex, []string{caseId})
+    if err != nil {
+        return nil, fmt.Errorf("failed to execute query: %v", err)
+    }
+    defer resultsIterator.Close()
+
+    c, err := s.getCaller(ctx)
+    if err != nil {
+        return nil, err
+    }
+
+    return s.collectCaseIndexRecords(ctx, resultsIterator, caseId, c)
+}
+
+// QueryRecordsByCaseWithPagination is the paginated form of QueryRecordsByCase.
+func (s *SmartContract) QueryRecordsByCaseWithPagination(ctx contractapi.TransactionContextInterface, caseId string, pageSize int32, bookmark string) (*PaginatedRecords, error) {
+    if err := validatePageSize(pageSize); err != nil {
+        return nil, err
+    }
+
+    resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(recordCaseIndex, []string{caseId}, pageSize, bookmark)
+    if err != nil {
+        return nil, fmt.Errorf("failed to execute query: %v", err)
+    }
+    defer resultsIterator.Close()
+
+    c, err := s.getCaller(ctx)
+    if err != nil {
+        return nil, err
+    }
+
+    records, err := s.collectCaseIndexRecords(ctx, resultsIterator, caseId, c)
+    if err != nil {
+        return nil, err
+    }
+    return &PaginatedRecords{Results: records, Bookmark: metadata.Bookmark, FetchedCount: metadata.FetchedRecordsCount}, nil
+}
+
+// collectCaseIndexRecords resolves record~case index entries to records the caller may access.
+func (s *SmartContract) collectCaseIndexRecords(ctx contractapi.TransactionContextInterface, resultsIterator shim.StateQueryIteratorInterface, caseId string, caller *caller) ([]*Record, error) {
+    var records []*Record
+    for resultsIterator.HasNext() {
+        qr, err := resultsIterator.Next()
+        if err != nil {
+            return nil, err
+        }
+        _, keyParts, err := ctx.GetStub().SplitCompositeKey(qr.Key)
+        if err != nil {
+            return nil, fmt.Errorf("failed to split index key %s: %v", qr.Key, err)
+        }
+        if len(keyParts) < 2 {
+            continue
+        }
+
+        recJSON, err := ctx.GetStub().GetState("record:" + keyParts[1])
+        if err != nil {
+            return nil, fmt.Errorf("failed to read record %s: %v", keyParts[1], err)
+        }
+        if recJSON == nil {
+            continue // Stale index entry
+        }
+        var r Record
+        if err := json.Unmarshal(recJSON, &r); err != nil {
+            return nil, err
+        }
+
+        if r.DocType == "record" && r.CaseID == caseId && s.recordAccessible(ctx, &r, caller) {
+            records = append(records, &r)
+        }
+    }
+    return records, nil
+}
+
+// RebuildRecordCaseIndex writes record~case index entries for every record on the ledger.
+// Run once after upgrading from a chaincode version that did not maintain the index. Only an org admin may run it.
+func (s *SmartContract) RebuildRecordCaseIndex(ctx contractapi.TransactionContextInterface) (int, error) {
+    c, err := s.getCaller(ctx)
+    if err != nil {
+        return 0, err
+    }
+    if !c.IsAdmin {
+        return 0, fmt.Errorf("caller is not an admin of %s", c.MSPID)
+    }
+
+    resultsIterator, err := ctx.GetStub().GetStateByRange("record:", "record:\uffff")
+    if err != nil {
+        return 0, fmt.Errorf("failed to execute record query: %v", err)
+    }
+    defer resultsIterator.Close()
+
+    indexed := 0
+    for resultsIterator.HasNext() {
+        qr, err := resultsIterator.Next()
+        if err != nil {
+            return indexed, err
+        }
+        var r Record
+        if err := json.Unmarshal(qr.Value, &r); err != nil {
+            return indexed, err
+        }
+        if r.DocType != "record" {
+            continue
+        }
+        if err := putRecordCaseIndex(ctx, r.CaseID, r.ID); err != nil {
+            return indexed, err
+        }
+        indexed++
+    }
+    return indexed, nil
+}
+
+// QueryRecords returns the records the caller's org may access that match searchJSON. searchJSON is
+// either a Mango query ({"selector": {...}}, see FILTERS) or the backend's SearchRecordsDto fields
+// (caseId, recordType, dateFrom, dateTo). CouchDB peers run the selector as a rich query;
+// LevelDB peers scan all records and evaluate it in memory.
+func (s *SmartContract) QueryRecords(ctx contractapi.TransactionContextInterface, searchJSON string) ([]*Record, error) {
+    selector, err := buildRecordSelector(searchJSON)
+    if err != nil {
+        return nil, err
+    }
+
+    resultsIterator, err := recordQueryIterator(ctx, selector)
+    if err != nil {
+        return nil, fmt.Errorf("failed to execute record query: %v", err)
+    }
+    defer resultsIterator.Close()
+
+    c, err := s.getCaller(ctx)
+    if err != nil {
+        return nil, err
+    }
+
+    return s.collectRecords(ctx, resultsIterator, c, selector)
+}
+
+// QueryRecordsWithPagination is the paginated form of QueryRecords. The bookmark format depends on the
+// peer's state database, so bookmarks must be passed back to the same kind of peer.
+func (s *SmartContract) QueryRecordsWithPagination(ctx contractapi.TransactionContextInterface, searchJSON string, pageSize int32, bookmark string) (*PaginatedRecords, error) {
+    if err := validatePageSize(pageSize); err != nil {
+        return nil, err
+    }
+    selector, err := buildRecordSelector(searchJSON)
+    if err != nil {
+        return nil, err
+    }
+
+    resultsIterator, nextBookmark, fetched, err := recordQueryIteratorWithPagination(ctx, selector, pageSize, bookmark)
+    if err != nil {
+        return nil, fmt.Errorf("failed to execute record query: %v", err)
+    }
+    defer resultsIterator.Close()
+
+    c, err := s.getCaller(ctx)
+    if err != nil {
+        return nil, err
+    }
+
+    records, err := s.collectRecords(ctx, resultsIterator, c, selector)
+    if err != nil {
+        return nil, err
+    }
+    return &PaginatedRecords{Results: records, Bookmark: nextBookmark, FetchedCount: fetched}, nil
+}
+
+// buildRecordSelector turns QueryRecords' searchJSON into a selector over Record fields.
+func buildRecordSelector(searchJSON string) (map[string]interface{}, error) {
+    params, err := parseFilters(searchJSON)
+    if err != nil {
+        return nil, err
+    }
+
+    selector := map[string]interface{}{}
+    if explicit, ok := params["selector"].(map[string]interface{}); ok {
+        for k, v := range explicit {
+            selector[k] = v
+        }
+    } else {
+        // SearchRecordsDto fields. orgMspId/organization only pick the gateway identity.
+        if v, ok := params["uploaderId"].(string); ok && v != "" {
+            return nil, fmt.Errorf("filtering by uploaderId is not supported: records do not store their uploader")
+        }
+        for _, field := range []string{"caseId", "recordType"} {
+            if v, ok := params[field].(string); ok && v != "" {
+                selector[field] = v
+            }
+        }
+        createdAt := map[string]interface{}{}
+        if v, ok := params["dateFrom"].(string); ok && v != "" {
+            createdAt["$gte"] = v
+        }
+        if v, ok := params["dateTo"].(string); ok && v != "" {
+            if !strings.Contains(v, "T") {
+                v += "T23:59:59.999999999Z" // Plain dates include the whole day
+            }
+            createdAt["$lte"] = v
+        }
+        if len(createdAt) > 0 {
+            selector["createdAt"] = createdAt
+        }
+    }
+    selector["docType"] = "record"
+    return selector, nil
+}
+
+// recordQueryIterator runs the selector as a CouchDB rich query. LevelDB peers reject rich queries as
+// not supported, in which case all record: keys are scanned instead and collectRecords applies the selector.
+func recordQueryIterator(ctx contractapi.TransactionContextInterface, selector map[string]interface{}) (shim.StateQueryIteratorInterface, error) {
+    query, err := json.Marshal(map[string]interface{}{"selector": couchSelector(selector)})
+    if err != nil {
+        return nil, err
+    }
+    resultsIterator, err := ctx.GetStub().GetQueryResult(string(query))
+    if err == nil {
+        return resultsIterator, nil
+    }
+    if !richQueryUnsupported(err) {
+        return nil, err
+    }
+    return ctx.GetStub().GetStateByRange("record:", "record:\uffff")
+}
+
+func recordQueryIteratorWithPagination(ctx contractapi.TransactionContextInterface, selector map[string]interface{}, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, string, int32, error) {
+    query, err := json.Marshal(map[string]interface{}{"selector": couchSelector(selector)})
+    if err != nil {
+        return nil, "", 0, err
+    }
+    resultsIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(string(query), pageSize, bookmark)
+    if err != nil {
+        if !richQueryUnsupported(err) {
+            return nil, "", 0, err
+        }
+        resultsIterator, metadata, err = ctx.GetStub().GetStateByRangeWithPagination("record:", "record:\uffff", pageSize, bookmark)
+        if err != nil {
+            return nil, "", 0, err
+        }
+    }
+    return resultsIterator, metadata.Bookmark, metadata.FetchedRecordsCount, nil
+}
+
+// richQueryUnsupported reports whether a rich query failed because the peer's state database is LevelDB,
+// which answers "ExecuteQuery not supported for leveldb". Any other error is a real query failure.
+func richQueryUnsupported(err error) bool {
+    return strings.Contains(err.Error(), "not supported")
+}
+
+// couchSelector rewrites a filter selector into CouchDB's dialect. CouchDB has no $prefix operator, so
+// {"$prefix": "abc"} becomes an anchored {"$regex": "^abc"} with the prefix's metacharacters escaped.
+func couchSelector(filters map[string]interface{}) map[string]interface{} {
+    out := make(map[string]interface{}, len(filters))
+    for field, condition := range filters {
+        switch cond := condition.(type) {
+        case []interface{}: // $and / $or clauses
+            clauses := make([]interface{}, len(cond))
+            for i, clause := range cond {
+                if sub, ok := clause.(map[string]interface{}); ok {
+                    clauses[i] = couchSelector(sub)
+                } else {
+                    clauses[i] = clause
+                }
+            }
+            out[field] = clauses
+        case map[string]interface{}:
+            operators := make(map[string]interface{}, len(cond))
+            for op, operand := range cond {
+                if prefix, ok := operand.(string); ok && op == "$prefix" {
+                    operators["$regex"] = "^" + regexp.QuoteMeta(prefix)
+                } else {
+                    operators[op] = operand
+                }
+            }
+            out[field] = operators
+        default:
+            out[field] = condition
+        }
+    }
+    return out
+}
+
+// collectRecords reads records from a query iterator, keeping those the caller may access that match the selector.
+func (s *SmartContract) collectRecords(ctx contractapi.TransactionContextInterface, resultsIterator shim.StateQueryIteratorInterface, caller *caller, selector map[string]interface{}) ([]*Record, error) {
+    var records []*Record
+    for resultsIterator.HasNext() {
+        qr, err := resultsIterator.Next()
+        if err != nil {
+            return nil, err
+        }
+        var r Record
+        if err := json.Unmarshal(qr.Value, &r); err != nil {
+            return nil, err
+        }
+
+        if r.DocType != "record" || !s.recordAccessible(ctx, &r, caller) {
+            continue
+        }
+
+        doc, err := toDocument(&r)
+        if err != nil {
+            return nil, err
+        }
+        matched, err := matchesFilters(doc, selector)
+        if err != nil {
+            return nil, err
+        }
+        if matched {
+            records = append(records, &r)
+        }
+    }
+    return records, nil
+}
+
+// recordAccessible reports whether the record may be listed for the caller, using the same decision as QueryRecord.
+func (s *SmartContract) recordAccessible(ctx contractapi.TransactionContextInterface, r *Record, caller *caller) bool {
+    if r.PolicyID == "" {
+        return false // Skip records without policy
+    }
+
+    policy, err := s.QueryPolicy(ctx, r.PolicyID)
+    if err != nil {
+        return false // Skip if policy can't be retrieved
+    }
+    return evaluateAccess(ctx, policy, recordGrantIndex, r.ID, caller) == nil
+}
+
+// UpdateRecordMetadata accepts a JSON map of fields to update for a record. Only an admin of the
+// organization holding the record may update it. A new policyId or ownerOrg must leave the record under
+// an existing policy that allows the owner organization.
+func (s *SmartContract) UpdateRecordMetadata(ctx contractapi.TransactionContextInterface, id string, metadataJSON string) error {
+    key := "record:" + id
+    recJSON, err := ctx.GetStub().GetState(key)
+    if err != nil {
+        return fmt.Errorf("failed to read record: %v", err)
+    }
+    if recJSON == nil {
+        return fmt.Errorf("record %s not found", id)
+    }
+
+    var rec Record
+    if err := json.Unmarshal(recJSON, &rec); err != nil {
+        return err
+    }
+    if rec.Status == RecordStatusRetired {
+        return fmt.Errorf("record %s is retired", id)
+    }
+    if err := s.requireOrgAdmin(ctx, rec.OwnerOrg); err != nil {
+        return err
+    }
+
+    var updates map[string]interface{}
+    if err := json.Unmarshal([]byte(metadataJSON), &updates); err != nil {
+        return fmt.Errorf("invalid metadata JSON: %v", err)
+    }
+
+    var updatedFields []string
+    previousPolicyID := rec.PolicyID
+    previousRecordType := rec.RecordType
+    previousOwnerOrg := rec.OwnerOrg
+    // A record stays in the case it was created in; moving it would make its org a stakeholder of another case
+    if v, ok := updates["caseId"].(string); ok && v != rec.CaseID {
+        return fmt.Errorf("the caseId of record %s cannot be changed", id)
+    }
+    if v, ok := updates["policyId"].(string); ok {
+        rec.PolicyID = v
+        updatedFields = append(updatedFields, "policyId")
+    }
+    if v, ok := updates["recordType"].(string); ok {
+        rec.RecordType = v
+        updatedFields = append(updatedFields, "recordType")
+    }
+    if v, ok := updates["ownerOrg"].(string); ok && v != rec.OwnerOrg {
+        // Ownership changes always land in the custody chain
+        if err := appendCustodyEvent(ctx, id, rec.OwnerOrg, v, "ownerOrg changed by UpdateRecordMetadata"); err != nil {
+            return err
+        }
+        rec.OwnerOrg = v
+        updatedFields = append(updatedFields, "ownerOrg")
+    }
+    // metadataJSON is recorded in the block, so a new description must come through the transient map.
+    // An empty description in metadataJSON clears it.
+    v, ok := updates["description"].(string)
+    if ok && v != "" {
+        return fmt.Errorf("description must be sent in the transient map under \"description\", not in metadataJSON")
+    }
+    transient, err := ctx.GetStub().GetTransient()
+    if err != nil {
+        return fmt.Errorf("failed to read transient data: %v", err)
+    }
+    if tv, found := transient["description"]; found {
+        v, ok = string(tv), true
+    }
+    if ok {
+        descriptionHash, err := putPrivateDescription(ctx, recordDetailsCollection, key, id, v)
+        if err != nil {
+            return err
+        }
+        rec.Description = ""
+        rec.DescriptionHash = descriptionHash
+        updatedFields = append(updatedFields, "description")
+    }
+    // Accept other metadata fields as needed.
+
+    // Moving a record to another policy or owner must not let the caller grant itself access
+    accessChanged := rec.PolicyID != previousPolicyID || rec.OwnerOrg != previousOwnerOrg
+    if accessChanged && rec.PolicyID == "" {
+        return fmt.Errorf("record %s must be governed by a policy", id)
+    }
+    if rec.PolicyID != "" && (accessChanged || rec.RecordType != previousRecordType) {
+        policy, err := s.QueryPolicy(ctx, rec.PolicyID)
+        if err != nil {
+            return fmt.Errorf("failed to get policy %s: %v", rec.PolicyID, err)
+        }
+        if !policyAllowsCategory(policy, rec.RecordType) {
+            return fmt.Errorf("record type %s is not in the categories of policy %s", rec.RecordType, rec.PolicyID)
+        }
+        if accessChanged && !policyAllowsOrg(policy, rec.OwnerOrg) {
+            return fmt.Errorf("organization %s not allowed by policy %s", rec.OwnerOrg, rec.PolicyID)
+        }
+    }
+
+    rec.UpdatedAt, rec.UpdatedBy, err = txAuthor(ctx)
+    if err != nil {
+        return err
+    }
+
+    newJSON, err := json.Marshal(rec)
+    if err != nil {
+        return err
+    }
+    if err := ctx.GetStub().PutState(key, newJSON); err != nil {
+        return err
+    }
+
+    // Backfill the record~case index for records created before it existed
+    if err := putRecordCaseIndex(ctx, rec.CaseID, id); err != nil {
+        return err
+    }
+    return emitEvent(ctx, EventRecordMetadataUpdated, recordEventPayload(ctx, &rec, updatedFields))
+}
+
+func recordEventPayload(ctx contractapi.TransactionContextInterface, rec *Record, updatedFields []string) RecordEventPayload {
+    return RecordEventPayload{
+        RecordID:      rec.ID,
+        CaseID:        rec.CaseID,
+        RecordType:    rec.RecordType,
+        OwnerOrg:      rec.OwnerOrg,
+        PolicyID:      rec.PolicyID,
+        UpdatedFields: updatedFields,
+        TxID:          ctx.GetStub().GetTxID(),
+    }
+}
+
+// --------------------------- RECORD VERSIONS ------------------------
+// Each file revision of a record is kept as a RecordVersion under record~version composite keys of
+// [recordId, zero-padded version], so they sort by version. The record itself always carries the
+// current version's FileHash and OffChainURI.
+
+const recordVersionIndex = "record~version"
+
+// AddRecordVersion replaces a record's file with a new revision and keeps the previous one in the
+// version list. Only an admin of the current custodian (Record.OwnerOrg) may add versions, and retired
+// records cannot change.
+func (s *SmartContract) AddRecordVersion(ctx contractapi.TransactionContextInterface, recordId, fileHash, offChainUri, note string) (*RecordVersion, error) {
+    if fileHash == "" {
+        return nil, fmt.Errorf("fileHash is required")
+    }
+    rec, err := s.readRecord(ctx, recordId)
+    if err != nil {
+        return nil, err
+    }
+    if rec.Status == RecordStatusRetired {
+        return nil, fmt.Errorf("record %s is retired", recordId)
+    }
+
+    // Replacing the file moves the record's integrity anchor, so it takes the same admin as UpdateRecordMetadata
+    if err := s.requireOrgAdmin(ctx, rec.OwnerOrg); err != nil {
+        return nil, err
+    }
+    c, err := s.getCaller(ctx)
+    if err != nil {
+        return nil, err
+    }
+
+    // Records created before versioning have no stored version 1; keep the original file as that version
+    currentKey, err := recordVersionKey(ctx, recordId, rec.Version)
+    if err != nil {
+        return nil, err
+    }
+    current, err := ctx.GetStub().GetState(currentKey)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read record version: %v", err)
+    }
+    if current == nil {
+        if err := putRecordVersion(ctx, initialRecordVersion(rec)); err != nil {
+            return nil, err
+        }
+    }
+
+    now, err := txTimestamp(ctx)
+    if err != nil {
+        return nil, err
+    }
+    version := &RecordVersion{
+        RecordID:    recordId,
+        Version:     rec.Version + 1,
+        FileHash:    fileHash,
+        OffChainURI: offChainUri,
+        Note:        note,
+        AddedBy:     c.ID,
+        AddedByMSP:  c.MSPID,
+        AddedAt:     now,
+        TxID:        ctx.GetStub().GetTxID(),
+    }
+    if err := putRecordVersion(ctx, version); err != nil {
+        return nil, err
+    }
+
+    rec.Version = version.Version
+    rec.FileHash = fileHash
+    rec.OffChainURI = offChainUri
+    rec.UpdatedAt = now
+    rec.UpdatedBy = c.ID
+    recJSON, err := json.Marshal(rec)
+    if err != nil {
+        return nil, err
+    }
+    if err := ctx.GetStub().PutState("record:"+recordId, recJSON); err != nil {
+        return nil, err
+    }
+    if err := emitEvent(ctx, EventRecordVersionAdded, recordEventPayload(ctx, rec, []string{"fileHash", "offChainUri", "version"})); err != nil {
+        return nil, err
+    }
+    return version, nil
+}
+
+// QueryRecordVersions returns every file version of a record, oldest first. The caller must pass the
+// record's policy, as in QueryRecord.
+func (s *SmartContract) QueryRecordVersions(ctx contractapi.TransactionContextInterface, recordId string) ([]*RecordVersion, error) {
+    rec, err := s.readRecord(ctx, recordId)
+    if err != nil {
+        return nil, err
+    }
+    if err := s.checkRecordAccess(ctx, rec); err != nil {
+        return nil, err
+    }
+
+    resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(recordVersionIndex, []string{recordId})
+    if err != nil {
+        return nil, fmt.Errorf("failed to execute record version query: %v", err)
+    }
+    defer resultsIterator.Close()
+
+    var versions []*RecordVersion
+    for resultsIterator.HasNext() {
+        qr, err := resultsIterator.Next()
+        if err != nil {
+            return nil, err
+        }
+        var v RecordVersion
+        if err := json.Unmarshal(qr.Value, &v); err != nil {
+            return nil, err
+        }
+        versions = append(versions, &v)
+    }
+    if len(versions) == 0 {
+        // Never revised and created before versioning
+        versions = append(versions, initialRecordVersion(rec))
+    }
+    return versions, nil
+}
+
+// initialRecordVersion describes a record's current file as version 1, for records without stored versions.
+func initialRecordVersion(rec *Record) *RecordVersion {
+    return &RecordVersion{
+        RecordID:    rec.ID,
+        Version:     1,
+        FileHash:    rec.FileHash,
+        OffChainURI: rec.OffChainURI,
+        AddedAt:     rec.CreatedAt,
+    }
+}
+
+func recordVersionKey(ctx contractapi.TransactionContextInterface, recordId string, version int) (string, error) {
+    key, err := ctx.GetStub().CreateCompositeKey(recordVersionIndex, []string{recordId, fmt.Sprintf("%010d", version)})
+    if err != nil {
+        return "", fmt.Errorf("failed to create %s key: %v", recordVersionIndex, err)
+    }
+    return key, nil
+}
+
+func putRecordVersion(ctx contractapi.TransactionContextInterface, version *RecordVersion) error {
+    versionKey, err := recordVersionKey(ctx, version.RecordID, version.Version)
+    if err != nil {
+        return err
+    }
+    versionJSON, err := json.Marshal(version)
+    if err != nil {
+        return err
+    }
+    return ctx.GetStub().PutState(versionKey, versionJSON)
+}
+
+// --------------------------- INTEGRITY ------------------------------
+// Ledger-side checks that a file fetched from off-chain storage is the one that was anchored, either
+// as a record's FileHash or as a leaf of an evidence batch stored under batch:<batchId>.
+
+// defaultHashAlgorithm is the algorithm the backend uses for Record.FileHash (hex-encoded SHA-256).
+const defaultHashAlgorithm = "SHA-256"
+
+// HashVerificationResult is returned by VerifyRecordHash. Only Decision and Reason are set when access was denied.
+type HashVerificationResult struct {
+    RecordID      string `json:"recordId"`
+    Decision      string `json:"decision"`
+    Reason        string `json:"reason,omitempty"`
+    Match         bool   `json:"match"`
+    CandidateHash string `json:"candidateHash"`
+    StoredHash    string `json:"storedHash,omitempty"`
+    HashAlgorithm string `json:"hashAlgorithm,omitempty"`
+    Version       int    `json:"version,omitempty"`
+    AnchorTxID    string `json:"anchorTxId,omitempty"` // transaction that stored the current file hash
+    AnchoredAt    string `json:"anchoredAt,omitempty"`
+}
+
+// VerifyRecordHash compares candidateHash with the record's current FileHash, case-insensitively, after
+// the same policy check as QueryRecord. With recordAudit the check is written to the audit trail
+// (action "verify"), in which case the transaction must be submitted and a denial is returned in the
+// result rather than as an error.
+func (s *SmartContract) VerifyRecordHash(ctx contractapi.TransactionContextInterface, recordId, candidateHash string, recordAudit bool) (*HashVerificationResult, error) {
+    rec, err := s.readRecord(ctx, recordId)
+    if err != nil {
+        return nil, err
+    }
+
+    result := &HashVerificationResult{
+        RecordID:      recordId,
+        Decision:      AuditDecisionAllowed,
+        CandidateHash: candidateHash,
+    }
+    accessErr := s.checkRecordAccess(ctx, rec)
+    if accessErr != nil {
+        if !recordAudit {
+            return nil, accessErr
+        }
+        result.Decision = AuditDecisionDenied
+        result.Reason = accessErr.Error()
+    } else {
+        result.Match = candidateHash != "" && strings.EqualFold(strings.TrimSpace(candidateHash), rec.FileHash)
+        result.StoredHash = rec.FileHash
+        result.HashAlgorithm = rec.HashAlgorithm
+        if result.HashAlgorithm == "" {
+            result.HashAlgorithm = defaultHashAlgorithm // Created before HashAlgorithm was stored
+        }
+        result.Version = rec.Version
+        result.AnchorTxID, result.AnchoredAt = recordHashAnchor(ctx, rec)
+    }
+
+    if recordAudit {
+        detail := "mismatch"
+        if result.Match {
+            detail = "match"
+        }
+        if accessErr != nil {
+            detail = ""
+        }
+        if _, err := s.writeAuditEntry(ctx, auditActionVerify, "record", recordId, detail, accessErr); err != nil {
+            return nil, err
+        }
+    }
+    return result, nil
+}
+
+// recordHashAnchor finds the transaction that stored the record's current file hash: the current
+// RecordVersion, or for records created before versioning the oldest history entry with that hash.
+// It returns empty strings if neither is available (e.g. the history database is disabled).
+func recordHashAnchor(ctx contractapi.TransactionContextInterface, rec *Record) (string, string) {
+    versionKey, err := recordVersionKey(ctx, rec.ID, rec.Version)
+    if err == nil {
+        if versionJSON, err := ctx.GetStub().GetState(versionKey); err == nil && versionJSON != nil {
+            var v RecordVersion
+            if err := json.Unmarshal(versionJSON, &v); err == nil && v.TxID != "" {
+                return v.TxID, v.AddedAt
+            }
+        }
+    }
+
+    resultsIterator, err := ctx.GetStub().GetHistoryForKey("record:" + rec.ID)
+    if err != nil {
+        return "", ""
+    }
+    defer resultsIterator.Close()
+
+    // History is returned newest first, so the last match is the oldest
+    var txID, timestamp string
+    for resultsIterator.HasNext() {
+        km, err := resultsIterator.Next()
+        if err != nil {
+            break
+        }
+        if km.IsDelete {
+            continue
+        }
+        var r Record
+        if err := json.Unmarshal(km.Value, &r); err != nil {
+            continue
+        }
+        if r.FileHash == rec.FileHash {
+            txID = km.TxId
+            timestamp = km.Timestamp.AsTime().UTC().Format(time.RFC3339)
+        }
+    }
+    return txID, timestamp
+}
+
+// batchCaseIndex maps caseId -> batchId for QueryEvidenceBatches.
+const batchCaseIndex = "batch~case"
+
+// MerkleProofStep is one sibling on the path from a leaf to the Merkle root. Position says on which
+// side of the running hash the sibling sits: "left" or "right".
+type MerkleProofStep struct {
+    Hash     string `json:"hash"`
+    Position string `json:"position"`
+}
+
+// BatchInclusionResult is returned by VerifyBatchInclusion.
+type BatchInclusionResult struct {
+    BatchID      string `json:"batchId"`
+    LeafHash     string `json:"leafHash"`
+    Included     bool   `json:"included"`
+    MerkleRoot   string `json:"merkleRoot"`
+    ComputedRoot string `json:"computedRoot"`
+}
+
+// AnchorEvidenceBatch stores the Merkle root of a bulk seizure instead of one record per file. The tree
+// follows RFC 6962: a leaf is SHA-256(0x00 || fileHash) over the file's raw SHA-256 and an inner node is
+// SHA-256(0x01 || left || right). The full file list lives at manifestUri. The batch ID is the transaction
+// ID. Only the organization owning the case may anchor batches, and the case must not be archived.
+func (s *SmartContract) AnchorEvidenceBatch(ctx contractapi.TransactionContextInterface, caseId, merkleRoot string, leafCount int, manifestUri string) (*EvidenceBatch, error) {
+    if leafCount <= 0 {
+        return nil, fmt.Errorf("leafCount must be positive")
+    }
+    root, err := decodeSHA256Hex(merkleRoot)
+    if err != nil {
+        return nil, fmt.Errorf("invalid merkleRoot: %v", err)
+    }
+
+    caseObj, err := s.readCase(ctx, caseId)
+    if err != nil {
+        return nil, err
+    }
+    if caseObj.Status == CaseStatusArchived {
+        return nil, fmt.Errorf("case %s is archived", caseId)
+    }
+    if err := s.checkCaseAccess(ctx, caseObj); err != nil {
+        return nil, err
+    }
+    c, err := s.getCaller(ctx)
+    if err != nil {
+        return nil, err
+    }
+    if c.MSPID != caseObj.Organization {
+        return nil, fmt.Errorf("organization %s cannot anchor evidence on case %s owned by %s", c.MSPID, caseId, caseObj.Organization)
+    }
+
+    now, clientID, err := txAuthor(ctx)
+    if err != nil {
+        return nil, err
+    }
+    clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+    if err != nil {
+        return nil, fmt.Errorf("failed to get client MSP ID: %v", err)
+    }
+
+    batch := EvidenceBatch{
+        DocType:       "batch",
+        BatchID:       ctx.GetStub().GetTxID(),
+        CaseID:        caseId,
+        MerkleRoot:    hex.EncodeToString(root),
+        LeafCount:     leafCount,
+        ManifestURI:   manifestUri,
+        HashAlgorithm: defaultHashAlgorithm,
+        AnchoredBy:    clientID,
+        AnchoredByMSP: clientMSPID,
+        AnchoredAt:    now,
+    }
+    batchJSON, err := json.Marshal(batch)
+    if err != nil {
+        return nil, err
+    }
+    if err := ctx.GetStub().PutState("batch:"+batch.BatchID, batchJSON); err != nil {
+        return nil, err
+    }
+    indexKey, err := ctx.GetStub().CreateCompositeKey(batchCaseIndex, []string{caseId, batch.BatchID})
+    if err != nil {
+        return nil, fmt.Errorf("failed to create index key: %v", err)
+    }
+    if err := ctx.GetStub().PutState(indexKey, []byte{0x00}); err != nil {
+        return nil, fmt.Errorf("failed to write index: %v", err)
+    }
+    if err := emitEvent(ctx, EventEvidenceBatchAnchored, BatchEventPayload{
+        BatchID:    batch.BatchID,
+        CaseID:     caseId,
+        MerkleRoot: batch.MerkleRoot,
+        LeafCount:  leafCount,
+        TxID:       batch.BatchID,
+    }); err != nil {
+        return nil, err
+    }
+    return &batch, nil
+}
+
+// VerifyBatchInclusion checks that leafHash is part of a batch by folding proofJSON, a list of
+// MerkleProofStep from the leaf upwards, into a root and comparing it with the anchored one.
+// The caller must be able to access the batch's case.
+func (s *SmartContract) VerifyBatchInclusion(ctx contractapi.TransactionContextInterface, batchId, leafHash, proofJSON string) (*BatchInclusionResult, error) {
+    batch, err := s.QueryEvidenceBatch(ctx, batchId)
+    if err != nil {
+        return nil, err
+    }
+
+    var proof []MerkleProofStep
+    if err := json.Unmarshal([]byte(proofJSON), &proof); err != nil {
+        return nil, fmt.Errorf("failed to unmarshal proof JSON: %v", err)
+    }
+    computedRoot, err := merkleRootFromProof(leafHash, proof, batch.LeafCount)
+    if err != nil {
+        return nil, err
+    }
+
+    return &BatchInclusionResult{
+        BatchID:      batchId,
+        LeafHash:     strings.ToLower(leafHash),
+        Included:     computedRoot == batch.MerkleRoot,
+        MerkleRoot:   batch.MerkleRoot,
+        ComputedRoot: computedRoot,
+    }, nil
+}
+
+// QueryEvidenceBatch returns a batch if the caller can access its case.
+func (s *SmartContract) QueryEvidenceBatch(ctx contractapi.TransactionContextInterface, batchId string) (*EvidenceBatch, error) {
+    batchJSON, err := ctx.GetStub().GetState("batch:" + batchId)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read batch: %v", err)
+    }
+    if batchJSON == nil {
+        return nil, fmt.Errorf("batch %s not found", batchId)
+    }
+    var batch EvidenceBatch
+    if err := json.Unmarshal(batchJSON, &batch); err != nil {
+        return nil, err
+    }
+
+    caseObj, err := s.readCase(ctx, batch.CaseID)
+    if err != nil {
+        return nil, err
+    }
+    if err := s.checkCaseAccess(ctx, caseObj); err != nil {
+        return nil, err
+    }
+    return &batch, nil
+}
+
+// QueryEvidenceBatches returns the batches anchored for a case the caller can access.
+func (s *SmartContract) QueryEvidenceBatches(ctx contractapi.TransactionContextInterface, caseId string) ([]*EvidenceBatch, error) {
+    caseObj, err := s.readCase(ctx, caseId)
+    if err != nil {
+        return nil, err
+    }
+    if err := s.checkCaseAccess(ctx, caseObj); err != nil {
+        return nil, err
+    }
+
+    resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(batchCaseIndex, []string{caseId})
+    if err != nil {
+        return nil, fmt.Errorf("failed to execute query: %v", err)
+    }
+    defer resultsIterator.Close()
+
+    var batches []*EvidenceBatch
+    for resultsIterator.HasNext() {
+        qr, err := resultsIterator.Next()
+        if err != nil {
+            return nil, err
+        }
+        _, keyParts, err := ctx.GetStub().SplitCompositeKey(qr.Key)
+        if err != nil {
+            return nil, fmt.Errorf("failed to split index key %s: %v", qr.Key, err)
+        }
+        if len(keyParts) < 2 {
+            continue
+        }
+        batchJSON, err := ctx.GetStub().GetState("batch:" + keyParts[1])
+        if err != nil {
+            return nil, fmt.Errorf("failed to read batch %s: %v", keyParts[1], err)
+        }
+        if batchJSON == nil {
+            continue
+        }
+        var batch EvidenceBatch
+        if err := json.Unmarshal(batchJSON, &batch); err != nil {
+            return nil, err
+        }
+        batches = append(batches, &batch)
+    }
+    return batches, nil
+}
+
+// Domain separation prefixes (RFC 6962) so that an inner node can never be passed off as a leaf.
+const (
+    merkleLeafPrefix = 0x00
+    merkleNodePrefix = 0x01
+)
+
+// merkleRootFromProof folds an inclusion proof for the file hash leafHash into a hex Merkle root.
+// A tree of leafCount leaves is at most ceil(log2(leafCount)) levels deep, so longer proofs are rejected.
+func merkleRootFromProof(leafHash string, proof []MerkleProofStep, leafCount int) (string, error) {
+    leaf, err := decodeSHA256Hex(leafHash)
+    if err != nil {
+        return "", fmt.Errorf("invalid leafHash: %v", err)
+    }
+    if leafCount <= 0 {
+        return "", fmt.Errorf("leafCount must be positive")
+    }
+    maxDepth := bits.Len(uint(leafCount - 1))
+    if len(proof) > maxDepth {
+        return "", fmt.Errorf("proof has %d steps; a tree of %d leaves allows at most %d", len(proof), leafCount, maxDepth)
+    }
+
+    sum := sha256.Sum256(append([]byte{merkleLeafPrefix}, leaf...))
+    computed := sum[:]
+    for i, step := range proof {
+        sibling, err := decodeSHA256Hex(step.Hash)
+        if err != nil {
+            return "", fmt.Errorf("invalid hash in proof step %d: %v", i, err)
+        }
+        node := []byte{merkleNodePrefix}
+        switch step.Position {
+        case "left":
+            node = append(append(node, sibling...), computed...)
+        case "right":
+            node = append(append(node, computed...), sibling...)
+        default:
+            return "", fmt.Errorf("invalid position %q in proof step %d, expected left or right", step.Position, i)
+        }
+        sum = sha256.Sum256(node)
+        computed = sum[:]
+    }
+    return hex.EncodeToString(computed), nil
+}
+
+// decodeSHA256Hex decodes a hex-encoded SHA-256 digest, in either case.
+func decodeSHA256Hex(h string) ([]byte, error) {
+    b, err := hex.DecodeString(strings.TrimSpace(h))
+    if err != nil {
+        return nil, err
+    }
+    if len(b) != sha256.Size {
+        return nil, fmt.Errorf("expected %d bytes, got %d", sha256.Size, len(b))
+    }
+    return b, nil
+}
+
+// --------------------------- CUSTODY --------------------------------
+// The chain of custody of a record is an append-only list of CustodyEvent stored under custody:<recordId>.
+
+// TransferCustody hands a record over to toOrg. Only an admin of the current custodian (Record.OwnerOrg)
+// may transfer, retired records cannot move, and the record's policy must allow the receiving organization.
+func (s *SmartContract) TransferCustody(ctx contractapi.TransactionContextInterface, recordId, toOrg, reason string) error {
+    if toOrg == "" {
+        return fmt.Errorf("target organization is required")
+    }
+    if reason == "" {
+        return fmt.Errorf("a reason is required to transfer custody of record %s", recordId)
+    }
+
+    rec, err := s.readRecord(ctx, recordId)
+    if err != nil {
+        return err
+    }
+    if rec.Status == RecordStatusRetired {
+        return fmt.Errorf("record %s is retired", recordId)
+    }
+    if err := s.requireOrgAdmin(ctx, rec.OwnerOrg); err != nil {
+        return err
+    }
+    if toOrg == rec.OwnerOrg {
+        return fmt.Errorf("record %s is already held by %s", recordId, toOrg)
+    }
+
+    if rec.PolicyID != "" {
+        policy, err := s.QueryPolicy(ctx, rec.PolicyID)
+        if err != nil {
+            return fmt.Errorf("failed to get policy %s: %v", rec.PolicyID, err)
+        }
+        if !policyAllowsOrg(policy, toOrg) {
+            return fmt.Errorf("organization %s not allowed by policy %s", toOrg, rec.PolicyID)
+        }
+    }
+
+    if err := appendCustodyEvent(ctx, recordId, rec.OwnerOrg, toOrg, reason); err != nil {
+        return err
+    }
+    rec.OwnerOrg = toOrg
+    rec.UpdatedAt, rec.UpdatedBy, err = txAuthor(ctx)
+    if err != nil {
+        return err
+    }
+
+    recJSON, err := json.Marshal(rec)
+    if err != nil {
+        return err
+    }
+    if err := ctx.GetStub().PutState("record:"+recordId, recJSON); err != nil {
+        return err
+    }
+    return emitEvent(ctx, EventCustodyTransferred, recordEventPayload(ctx, rec, nil))
+}
+
+// GetCustodyChain returns the custody events of a record, oldest first. The caller must pass the same
+// policy check as QueryRecord.
+func (s *SmartContract) GetCustodyChain(ctx contractapi.TransactionContextInterface, recordId string) ([]*CustodyEvent, error) {
+    rec, err := s.readRecord(ctx, recordId)
+    if err != nil {
+        return nil, err
+    }
+    if err := s.checkRecordAccess(ctx, rec); err != nil {
+        return nil, err
+    }
+    return readCustodyChain(ctx, recordId)
+}
+
+func readCustodyChain(ctx contractapi.TransactionContextInterface, recordId string) ([]*CustodyEvent, error) {
+    chainJSON, err := ctx.GetStub().GetState("custody:" + recordId)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read custody chain: %v", err)
+    }
+    var chain []*CustodyEvent
+    if chainJSON == nil {
+        return chain, nil
+    }
+    if err := json.Unmarshal(chainJSON, &chain); err != nil {
+        return nil, err
+    }
+    return chain, nil
+}
+
+// appendCustodyEvent records a custody change made by the submitting identity in the current transaction.
+func appendCustodyEvent(ctx contractapi.TransactionContextInterface, recordId, fromOrg, toOrg, reason string) error {
+    chain, err := readCustodyChain(ctx, recordId)
+    if err != nil {
+        return err
+    }
+
+    clientID, err := ctx.GetClientIdentity().GetID()
+    if err != nil {
+        return fmt.Errorf("failed to get client ID: %v", err)
+    }
+    clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+    if err != nil {
+        return fmt.Errorf("failed to get client MSP ID: %v", err)
+    }
+    now, err := txTimestamp(ctx)
+    if err != nil {
+        return err
+    }
+
+    chain = append(chain, &CustodyEvent{
+        RecordID:     recordId,
+        FromOrg:      fromOrg,
+        ToOrg:        toOrg,
+        SubmittedBy:  clientID,
+        SubmitterMSP: clientMSPID,
+        Reason:       reason,
+        TxID:         ctx.GetStub().GetTxID(),
+        Timestamp:    now,
+    })
+
+    chainJSON, err := json.Marshal(chain)
+    if err != nil {
+        return err
+    }
+    return ctx.GetStub().PutState("custody:"+recordId, chainJSON)
+}
+
+// --------------------------- ARCHIVAL -------------------------------
+// Cases are archived rather than deleted. ArchiveCase requires every record of the case to be retired
+// and no legal hold to be in place. A hard delete additionally needs force and a deletion approval from
+// every stakeholder organization (the case's organization and each organization holding one of its
+// records), and from at least minDeletionApprovals organizations in all. A case whose records all
+// belong to its own organization therefore still needs a second registered organization to approve.
+// Holds and approvals are stored under hold~case [caseId, holdId] and deletion~case [caseId, mspId]
+// composite keys.
+
+const (
+    legalHoldIndex       = "hold~case"
+    caseDeletionIndex    = "deletion~case"
+    minDeletionApprovals = 2
+)
+
+// ArchiveCase marks a case Archived. Only an admin of the owning organization may archive, and only
+// once all of the case's records are retired and no legal hold remains.
+func (s *SmartContract) ArchiveCase(ctx contractapi.TransactionContextInterface, id, reason string) error {
+    caseObj, err := s.readCase(ctx, id)
+    if err != nil {
+        return err
+    }
+    if err := s.requireOrgAdmin(ctx, caseObj.Organization); err != nil {
+        return err
+    }
+    if caseObj.Status == CaseStatusArchived {
+        return fmt.Errorf("case %s is already archived", id)
+    }
+    if err := s.checkCaseReleasable(ctx, id); err != nil {
+        return err
+    }
+    return s.changeCaseStatus(ctx, caseObj, CaseStatusArchived, reason)
+}
+
+// RetireRecord marks a record retired so that its case can be archived. A retired record stays readable
+// but can no longer be updated. Only an admin of the organization holding the record may retire it.
+func (s *SmartContract) RetireRecord(ctx contractapi.TransactionContextInterface, recordId, reason string) error {
+    if reason == "" {
+        return fmt.Errorf("a reason is required to retire record %s", recordId)
+    }
+    rec, err := s.readRecord(ctx, recordId)
+    if err != nil {
+        return err
+    }
+    if err := s.requireOrgAdmin(ctx, rec.OwnerOrg); err != nil {
+        return err
+    }
+    if rec.Status == RecordStatusRetired {
+        return fmt.Errorf("record %s is already retired", recordId)
+    }
+
+    rec.Status = RecordStatusRetired
+    rec.StatusReason = reason
+    rec.UpdatedAt, rec.UpdatedBy, err = txAuthor(ctx)
+    if err != nil {
+        return err
+    }
+    recJSON, err := json.Marshal(rec)
+    if err != nil {
+        return err
+    }
+    if err := ctx.GetStub().PutState("record:"+recordId, recJSON); err != nil {
+        return err
+    }
+    return emitEvent(ctx, EventRecordRetired, recordEventPayload(ctx, rec, []string{"status"}))
+}
+
+// PlaceLegalHold blocks archival and deletion of a case until the hold is released. It may be placed
+// by an admin of the owning organization or by a judge who can access the case. The hold ID is the
+// transaction ID.
+func (s *SmartContract) PlaceLegalHold(ctx contractapi.TransactionContextInterface, caseId, reason string) (*LegalHold, error) {
+    if reason == "" {
+        return nil, fmt.Errorf("a reason is required to place a legal hold")
+    }
+    caseObj, err := s.readCase(ctx, caseId)
+    if err != nil {
+        return nil, err
+    }
+    c, err := s.requireHoldAuthority(ctx, caseObj)
+    if err != nil {
+        return nil, err
+    }
+    now, err := txTimestamp(ctx)
+    if err != nil {
+        return nil, err
+    }
+
+    hold := LegalHold{
+        DocType:     "legalHold",
+        HoldID:      ctx.GetStub().GetTxID(),
+        CaseID:      caseId,
+        Reason:      reason,
+        PlacedBy:    c.ID,
+        PlacedByMSP: c.MSPID,
+        PlacedAt:    now,
+    }
+    holdKey, err := ctx.GetStub().CreateCompositeKey(legalHoldIndex, []string{caseId, hold.HoldID})
+    if err != nil {
+        return nil, fmt.Errorf("failed to create hold key: %v", err)
+    }
+    holdJSON, err := json.Marshal(hold)
+    if err != nil {
+        return nil, err
+    }
+    if err := ctx.GetStub().PutState(holdKey, holdJSON); err != nil {
+        return nil, err
+    }
+    if err := emitEvent(ctx, EventLegalHoldPlaced, LegalHoldEventPayload{
+        CaseID: caseId,
+        HoldID: hold.HoldID,
+        TxID:   ctx.GetStub().GetTxID(),
+    }); err != nil {
+        return nil, err
+    }
+    return &hold, nil
+}
+
+// ReleaseLegalHold lifts a hold. The same identities that may place a hold may release it; the
+// released hold remains in the key's history.
+func (s *SmartContract) ReleaseLegalHold(ctx contractapi.TransactionContextInterface, caseId, holdId, reason string) error {
+    if reason == "" {
+        return fmt.Errorf("a reason is required to release a legal hold")
+    }
+    caseObj, err := s.readCase(ctx, caseId)
+    if err != nil {
+        return err
+    }
+    if _, err := s.requireHoldAuthority(ctx, caseObj); err != nil {
+        return err
+    }
+
+    holdKey, err := ctx.GetStub().CreateCompositeKey(legalHoldIndex, []string{caseId, holdId})
+    if err != nil {
+        return fmt.Errorf("failed to create hold key: %v", err)
+    }
+    holdJSON, err := ctx.GetStub().GetState(holdKey)
+    if err != nil {
+        return fmt.Errorf("failed to read legal hold: %v", err)
+    }
+    if holdJSON == nil {
+        return fmt.Errorf("legal hold %s not found on case %s", holdId, caseId)
+    }
+    if err := ctx.GetStub().DelState(holdKey); err != nil {
+        return err
+    }
+    return emitEvent(ctx, EventLegalHoldReleased, LegalHoldEventPayload{
+        CaseID: caseId,
+        HoldID: holdId,
+        TxID:   ctx.GetStub().GetTxID(),
+    })
+}
+
+// QueryLegalHolds returns the holds currently in place on a case the caller may access.
+func (s *SmartContract) QueryLegalHolds(ctx contractapi.TransactionContextInterface, caseId string) ([]*LegalHold, error) {
+    caseObj, err := s.readCase(ctx, caseId)
+    if err != nil {
+        return nil, err
+    }
+    if err := s.checkCaseAccess(ctx, caseObj); err != nil {
+        return nil, err
+    }
+    return readLegalHolds(ctx, caseId)
+}
+
+// ApproveCaseDeletion records the caller's organization's approval to hard delete an archived case.
+// The caller must be an admin of one of the case's stakeholder organizations (see caseStakeholders)
+// or of another registered organization.
+func (s *SmartContract) ApproveCaseDeletion(ctx contractapi.TransactionContextInterface, caseId string) error {
+    caseObj, err := s.readCase(ctx, caseId)
+    if err != nil {
+        return err
+    }
+    if caseObj.Status != CaseStatusArchived {
+        return fmt.Errorf("case %s must be archived before its deletion can be approved", caseId)
+    }
+
+    c, err := s.getCaller(ctx)
+    if err != nil {
+        return err
+    }
+    if !c.IsAdmin {
+        return fmt.Errorf("caller is not an admin of %s", c.MSPID)
+    }
+    stakeholders, err := s.caseStakeholders(ctx, caseObj)
+    if err != nil {
+        return err
+    }
+    stakeholder := false
+    for _, org := range stakeholders {
+        if org == c.MSPID {
+            stakeholder = true
+            break
+        }
+    }
+    if !stakeholder {
+        org, err := s.findOrganization(ctx, c.MSPID)
+        if err != nil {
+            return err
+        }
+        if org == nil {
+            return fmt.Errorf("organization %s has no stake in case %s and is not registered", c.MSPID, caseId)
+        }
+    }
+
+    now, err := txTimestamp(ctx)
+    if err != nil {
+        return err
+    }
+    approval := CaseDeletionApproval{
+        CaseID:     caseId,
+        MSPID:      c.MSPID,
+        ApprovedBy: c.ID,
+        ApprovedAt: now,
+        TxID:       ctx.GetStub().GetTxID(),
+    }
+    approvalKey, err := ctx.GetStub().CreateCompositeKey(caseDeletionIndex, []string{caseId, c.MSPID})
+    if err != nil {
+        return fmt.Errorf("failed to create approval key: %v", err)
+    }
+    approvalJSON, err := json.Marshal(approval)
+    if err != nil {
+        return err
+    }
+    if err := ctx.GetStub().PutState(approvalKey, approvalJSON); err != nil {
+        return err
+    }
+    return emitEvent(ctx, EventCaseDeletionApproved, CaseEventPayload{
+        CaseID:       caseId,
+        Status:       caseObj.Status,
+        Organization: c.MSPID,
+        TxID:         ctx.GetStub().GetTxID(),
+    })
+}
+
+// DeleteCase hard deletes an archived case. The caller must be an admin of the owning organization
+// (Case.Organization). Without force it always fails: cases are archived, not deleted. With force,
+// no legal hold or active record may remain, every stakeholder organization must have called
+// ApproveCaseDeletion, and at least minDeletionApprovals organizations must have approved. The case's
+// earlier versions remain in the ledger history.
+func (s *SmartContract) DeleteCase(ctx contractapi.TransactionContextInterface, id string, force bool) error {
+    caseObj, err := s.readCase(ctx, id)
+    if err != nil {
+        return err
+    }
+    if err := s.requireOrgAdmin(ctx, caseObj.Organization); err != nil {
+        return err
+    }
+    if !force {
+        return fmt.Errorf("case %s cannot be deleted without force; use ArchiveCase instead", id)
+    }
+    if caseObj.Status != CaseStatusArchived {
+        return fmt.Errorf("case %s must be archived before it can be deleted", id)
+    }
+    if err := s.checkCaseReleasable(ctx, id); err != nil {
+        return err
+    }
+
+    approvals, err := readCaseDeletionApprovals(ctx, id)
+    if err != nil {
+        return err
+    }
+    approved := map[string]bool{}
+    for _, a := range approvals {
+        approved[a.MSPID] = true
+    }
+    stakeholders, err := s.caseStakeholders(ctx, caseObj)
+    if err != nil {
+        return err
+    }
+    var missing []string
+    for _, org := range stakeholders {
+        if !approved[org] {
+            missing = append(missing, org)
+        }
+    }
+    if len(missing) > 0 {
+        return fmt.Errorf("deleting case %s still needs approval from %s", id, strings.Join(missing, ", "))
+    }
+    if len(approved) < minDeletionApprovals {
+        return fmt.Errorf("deleting case %s needs approval from at least %d organizations, has %d", id, minDeletionApprovals, len(approved))
+    }
+
+    for _, a := range approvals {
+        approvalKey, err := ctx.GetStub().CreateCompositeKey(caseDeletionIndex, []string{id, a.MSPID})
+        if err != nil {
+            return fmt.Errorf("failed to create approval key: %v", err)
+        }
+        if err := ctx.GetStub().DelState(approvalKey); err != nil {
+            return err
+        }
+    }
+    if err := ctx.GetStub().DelState("case:" + id); err != nil {
+        return err
+    }
+    if err := ctx.GetStub().DelPrivateData(caseDetailsCollection, "case:"+id); err != nil {
+        return fmt.Errorf("failed to delete private details: %v", err)
+    }
+    return emitEvent(ctx, EventCaseDeleted, CaseEventPayload{
+        CaseID:       id,
+        Status:       caseObj.Status,
+        Organization: caseObj.Organization,
+        PolicyID:     caseObj.PolicyID,
+        TxID:         ctx.GetStub().GetTxID(),
+    })
+}
+
+// caseStakeholders returns the organizations that must approve deleting a case: its organization and
+// the distinct OwnerOrgs of its records, retired or not.
+func (s *SmartContract) caseStakeholders(ctx contractapi.TransactionContextInterface, caseObj *Case) ([]string, error) {
+    stakeholders := []string{caseObj.Organization}
+    seen := map[string]bool{caseObj.Organization: true}
+
+    resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(recordCaseIndex, []string{caseObj.ID})
+    if err != nil {
+        return nil, fmt.Errorf("failed to execute query: %v", err)
+    }
+    defer resultsIterator.Close()
+
+    for resultsIterator.HasNext() {
+        qr, err := resultsIterator.Next()
+        if err != nil {
+            return nil, err
+        }
+        _, keyParts, err := ctx.GetStub().SplitCompositeKey(qr.Key)
+        if err != nil {
+            return nil, fmt.Errorf("failed to split index key %s: %v", qr.Key, err)
+        }
+        if len(keyParts) < 2 {
+            continue
+        }
+        rec, err := s.readRecord(ctx, keyParts[1])
+        if err != nil || rec.CaseID != caseObj.ID {
+            continue // Stale index entry
+        }
+        if rec.OwnerOrg != "" && !seen[rec.OwnerOrg] {
+            seen[rec.OwnerOrg] = true
+            stakeholders = append(stakeholders, rec.OwnerOrg)
+        }
+    }
+    return stakeholders, nil
+}
+
+// checkCaseReleasable returns an error if the case still has a legal hold or a record that is not retired.
+func (s *SmartContract) checkCaseReleasable(ctx contractapi.TransactionContextInterface, caseId string) error {
+    holds, err := readLegalHolds(ctx, caseId)
+    if err != nil {
+        return err
+    }
+    if len(holds) > 0 {
+        return fmt.Errorf("case %s is under %d legal hold(s)", caseId, len(holds))
+    }
+
+    resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(recordCaseIndex, []string{caseId})
+    if err != nil {
+        return fmt.Errorf("failed to execute query: %v", err)
+    }
+    defer resultsIterator.Close()
+
+    var active []string
+    for resultsIterator.HasNext() {
+        qr, err := resultsIterator.Next()
+        if err != nil {
+            return err
+        }
+        _, keyParts, err := ctx.GetStub().SplitCompositeKey(qr.Key)
+        if err != nil {
+            return fmt.Errorf("failed to split index key %s: %v", qr.Key, err)
+        }
+        if len(keyParts) < 2 {
+            continue
+        }
+        rec, err := s.readRecord(ctx, keyParts[1])
+        if err != nil {
+            continue // Stale index entry
+        }
+        if rec.CaseID == caseId && rec.Status != RecordStatusRetired {
+            active = append(active, rec.ID)
+        }
+    }
+    if len(active) > 0 {
+        return fmt.Errorf("case %s has active records: %s", caseId, strings.Join(active, ", "))
+    }
+    return nil
+}
+
+// requireCaseOpenForRecords checks that records may be attached to caseId: the case must exist, must not
+// be archived, and the caller must belong to its organization or pass its access check. An empty caseId
+// leaves the record outside any case.
+func (s *SmartContract) requireCaseOpenForRecords(ctx contractapi.TransactionContextInterface, caseId string) error {
+    if caseId == "" {
+        return nil
+    }
+    caseObj, err := s.readCase(ctx, caseId)
+    if err != nil {
+        return err
+    }
+    if caseObj.Status == CaseStatusArchived {
+        return fmt.Errorf("case %s is archived", caseId)
+    }
+    clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+    if err != nil {
+        return fmt.Errorf("failed to get client MSP ID: %v", err)
+    }
+    if clientMSPID == caseObj.Organization {
+        return nil
+    }
+    if err := s.checkCaseAccess(ctx, caseObj); err != nil {
+        return fmt.Errorf("cannot attach records to case %s: %v", caseId, err)
+    }
+    return nil
+}
+
+// requireHoldAuthority allows admins of the owning organization and judges who can access the case.
+func (s *SmartContract) requireHoldAuthority(ctx contractapi.TransactionContextInterface, caseObj *Case) (*caller, error) {
+    c, err := s.getCaller(ctx)
+    if err != nil {
+        return nil, err
+    }
+    if c.IsAdmin && c.MSPID == caseObj.Organization {
+        return c, nil
+    }
+    if c.Role == judgeRole {
+        if err := s.checkCaseAccess(ctx, caseObj); err != nil {
+            return nil, err
+        }
+        return c, nil
+    }
+    return nil, fmt.Errorf("only an admin of %s or a judge may manage legal holds on case %s", caseObj.Organization, caseObj.ID)
+}
+
+func readLegalHolds(ctx contractapi.TransactionContextInterface, caseId string) ([]*LegalHold, error) {
+    resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(legalHoldIndex, []string{caseId})
+    if err != nil {
+        return nil, fmt.Errorf("failed to query legal holds: %v", err)
+    }
+    defer resultsIterator.Close()
+
+    var holds []*LegalHold
+    for resultsIterator.HasNext() {
+        qr, err := resultsIterator.Next()
+        if err != nil {
+            return nil, err
+        }
+        var h LegalHold
+        if err := json.Unmarshal(qr.Value, &h); err != nil {
+            return nil, err
+        }
+        holds = append(holds, &h)
+    }
+    return holds, nil
+}
+
+func readCaseDeletionApprovals(ctx contractapi.TransactionContextInterface, caseId string) ([]*CaseDeletionApproval, error) {
+    resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(caseDeletionIndex, []string{caseId})
+    if err != nil {
+        return nil, fmt.Errorf("failed to query deletion approvals: %v", err)
+    }
+    defer resultsIterator.Close()
+
+    var approvals []*CaseDeletionApproval
+    for resultsIterator.HasNext() {
+        qr, err := resultsIterator.Next()
+        if err != nil {
+            return nil, err
+        }
+        var a CaseDeletionApproval
+        if err := json.Unmarshal(qr.Value, &a); err != nil {
+            return nil, err
+        }
+        approvals = append(approvals, &a)
+    }
+    return approvals, nil
+}
+
+// --------------------------- GRANTS ---------------------------------
+// A grant gives one user time-limited access to a single record or case. Grants are stored under
+// grant~record and grant~case composite keys of [resourceId, grantee] and are checked by
+// checkRecordAccess and checkCaseAccess.
+
+const (
+    recordGrantIndex = "grant~record"
+    caseGrantIndex   = "grant~case"
+)
+
+// GrantRecordAccess lets grantee (a username or client identity ID) read the record until expiresAt
+// (RFC3339). Only an admin of the organization holding the record may grant access. Granting again
+// replaces the previous expiry.
+func (s *SmartContract) GrantRecordAccess(ctx contractapi.TransactionContextInterface, recordId, grantee, expiresAt string) error {
+    if grantee == "" {
+        return fmt.Errorf("grantee is required")
+    }
+    rec, err := s.readRecord(ctx, recordId)
+    if err != nil {
+        return err
+    }
+    if err := s.requireOrgAdmin(ctx, rec.OwnerOrg); err != nil {
+        return err
+    }
+
+    grant := AccessGrant{RecordID: recordId, Grantee: grantee, GrantedByMSP: rec.OwnerOrg}
+    if err := putAccessGrant(ctx, recordGrantIndex, recordId, &grant, expiresAt); err != nil {
+        return err
+    }
+    return emitEvent(ctx, EventRecordAccessGranted, GrantEventPayload{
+        RecordID:  recordId,
+        Grantee:   grantee,
+        ExpiresAt: grant.ExpiresAt,
+        TxID:      ctx.GetStub().GetTxID(),
+    })
+}
+
+// RevokeRecordAccess removes grantee's grant on the record before it expires.
+// Only an admin of the organization holding the record may revoke access.
+func (s *SmartContract) RevokeRecordAccess(ctx contractapi.TransactionContextInterface, recordId, grantee string) error {
+    rec, err := s.readRecord(ctx, recordId)
+    if err != nil {
+        return err
+    }
+    if err := s.requireOrgAdmin(ctx, rec.OwnerOrg); err != nil {
+        return err
+    }
+
+    if err := deleteAccessGrant(ctx, recordGrantIndex, recordId, grantee); err != nil {
+        return err
+    }
+    return emitEvent(ctx, EventRecordAccessRevoked, GrantEventPayload{
+        RecordID: recordId,
+        Grantee:  grantee,
+        TxID:     ctx.GetStub().GetTxID(),
+    })
+}
+
+// RevokeCaseAccess removes grantee's grant on the case before it expires.
+// Only an admin of the organization owning the case may revoke access.
+func (s *SmartContract) RevokeCaseAccess(ctx contractapi.TransactionContextInterface, caseId, grantee string) error {
+    caseObj, err := s.readCase(ctx, caseId)
+    if err != nil {
+        return err
+    }
+    if err := s.requireOrgAdmin(ctx, caseObj.Organization); err != nil {
+        return err
+    }
+
+    if err := deleteAccessGrant(ctx, caseGrantIndex, caseId, grantee); err != nil {
+        return err
+    }
+    return emitEvent(ctx, EventCaseAccessRevoked, GrantEventPayload{
+        CaseID:  caseId,
+        Grantee: grantee,
+        TxID:    ctx.GetStub().GetTxID(),
+    })
+}
+
+// QueryRecordAccessGrants lists the grants on a record, including expired ones.
+// Only an admin of the organization holding the record may list them.
+func (s *SmartContract) QueryRecordAccessGrants(ctx contractapi.TransactionContextInterface, recordId string) ([]*AccessGrant, error) {
+    rec, err := s.readRecord(ctx, recordId)
+    if err != nil {
+        return nil, err
+    }
+    if err := s.requireOrgAdmin(ctx, rec.OwnerOrg); err != nil {
+        return nil, err
+    }
+    return queryAccessGrants(ctx, recordGrantIndex, recordId)
+}
+
+// QueryCaseAccessGrants lists the grants on a case, including expired ones.
+// Only an admin of the organization owning the case may list them.
+func (s *SmartContract) QueryCaseAccessGrants(ctx contractapi.TransactionContextInterface, caseId string) ([]*AccessGrant, error) {
+    caseObj, err := s.readCase(ctx, caseId)
+    if err != nil {
+        return nil, err
+    }
+    if err := s.requireOrgAdmin(ctx, caseObj.Organization); err != nil {
+        return nil, err
+    }
+    return queryAccessGrants(ctx, caseGrantIndex, caseId)
+}
+
+// putAccessGrant validates expiresAt against the transaction timestamp, stamps the grant and stores it under index.
+func putAccessGrant(ctx contractapi.TransactionContextInterface, index, resourceId string, grant *AccessGrant, expiresAt string) error {
+    expiry, err := time.Parse(time.RFC3339, expiresAt)
+    if err != nil {
+        return fmt.Errorf("invalid expiresAt %q, expected RFC3339: %v", expiresAt, err)
+    }
+    now, clientID, err := txAuthor(ctx)
+    if err != nil {
+        return err
+    }
+    nowTime, err := time.Parse(time.RFC3339, now)
+    if err != nil {
+        return err
+    }
+    if !expiry.After(nowTime) {
+        return fmt.Errorf("expiresAt %s is not in the future", expiresAt)
+    }
+
+    grant.DocType = "grant"
+    grant.ExpiresAt = expiry.UTC().Format(time.RFC3339)
+    grant.GrantedBy = clientID
+    grant.GrantedAt = now
+
+    grantKey, err := ctx.GetStub().CreateCompositeKey(index, []string{resourceId, grant.Grantee})
+    if err != nil {
+        return fmt.Errorf("failed to create grant key: %v", err)
+    }
+    grantJSON, err := json.Marshal(grant)
+    if err != nil {
+        return err
+    }
+    return ctx.GetStub().PutState(grantKey, grantJSON)
+}
+
+func deleteAccessGrant(ctx contractapi.TransactionContextInterface, index, resourceId, grantee string) error {
+    grantKey, err := ctx.GetStub().CreateCompositeKey(index, []string{resourceId, grantee})
+    if err != nil {
+        return fmt.Errorf("failed to create grant key: %v", err)
+    }
+    grantJSON, err := ctx.GetStub().GetState(grantKey)
+    if err != nil {
+        return fmt.Errorf("failed to read grant: %v", err)
+    }
+    if grantJSON == nil {
+        return fmt.Errorf("no grant on %s for %s", resourceId, grantee)
+    }
+    return ctx.GetStub().DelState(grantKey)
+}
+
+func queryAccessGrants(ctx contractapi.TransactionContextInterface, index, resourceId string) ([]*AccessGrant, error) {
+    resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(index, []string{resourceId})
+    if err != nil {
+        return nil, fmt.Errorf("failed to query grants: %v", err)
+    }
+    defer resultsIterator.Close()
+
+    var grants []*AccessGrant
+    for resultsIterator.HasNext() {
+        qr, err := resultsIterator.Next()
+        if err != nil {
+            return nil, err
+        }
+        var g AccessGrant
+        if err := json.Unmarshal(qr.Value, &g); err != nil {
+            return nil, err
+        }
+        grants = append(grants, &g)
+    }
+    return grants, nil
+}
+
+// hasAccessGrant reports whether the caller holds a grant on the resource that has not expired
+// as of the transaction timestamp. Grants are looked up by username and by client identity ID.
+func hasAccessGrant(ctx contractapi.TransactionContextInterface, index, resourceId string, c *caller) (bool, error) {
+    ts, err := ctx.GetStub().GetTxTimestamp()
+    if err != nil {
+        return false, fmt.Errorf("failed to get transaction timestamp: %v", err)
+    }
+    now := ts.AsTime()
+
+    for _, grantee := range []string{c.Username, c.ID} {
+        if grantee == "" {
+            continue
+        }
+        grantKey, err := ctx.GetStub().CreateCompositeKey(index, []string{resourceId, grantee})
+        if err != nil {
+            return false, fmt.Errorf("failed to create grant key: %v", err)
+        }
+        grantJSON, err := ctx.GetStub().GetState(grantKey)
+        if err != nil {
+            return false, fmt.Errorf("failed to read grant: %v", err)
+        }
+        if grantJSON == nil {
+            continue
+        }
+        var g AccessGrant
+        if err := json.Unmarshal(grantJSON, &g); err != nil {
+            return false, err
+        }
+        expiry, err := time.Parse(time.RFC3339, g.ExpiresAt)
         if err != nil {
-            return nil, fmt.Errorf("failed to get policy %s: %v", caseObj.PolicyID, err)
+            continue // Unreadable expiry never grants access
+        }
+        if now.Before(expiry) {
+            return true, nil
         }
+    }
+    return false, nil
+}
+
+// evaluateAccess is the access decision for a case or record: its policy, then any unexpired grant under
+// index. A grant lets the caller past the allow lists, but never past a deny rule.
+func evaluateAccess(ctx contractapi.TransactionContextInterface, policy *Policy, index, resourceId string, c *caller) error {
+    accessErr := evaluatePolicy(policy, c)
+    if accessErr == nil {
+        return nil
+    }
+    if policyDenial(policy, c) != nil {
+        return accessErr
+    }
+    granted, err := hasAccessGrant(ctx, index, resourceId, c)
+    if err != nil {
+        return err
+    }
+    if granted {
+        return nil
+    }
+    return accessErr
+}
+
+// --------------------------- ACCESS REQUESTS ------------------------
+// Users who are refused by a policy can ask the owning organization for access. Requests are stored
+// under accessrequest:<requestId> (the requesting transaction's ID) and indexed by resource under
+// request~resource. A request is never deleted; each step is appended to its Steps.
+
+const accessRequestIndex = "request~resource"
+
+// RequestAccess files a pending request for access to a record or case. resourceId is looked up as a
+// record first, then as a case. The requester is the caller's bound username, or its client identity ID.
+func (s *SmartContract) RequestAccess(ctx contractapi.TransactionContextInterface, resourceId, justification string) (*AccessRequest, error) {
+    if justification == "" {
+        return nil, fmt.Errorf("a justification is required")
+    }
+
+    c, err := s.getCaller(ctx)
+    if err != nil {
+        return nil, err
+    }
+
+    resourceType, ownerOrg, err := s.resolveAccessResource(ctx, resourceId)
+    if err != nil {
+        return nil, err
+    }
+
+    requester := c.Username
+    if requester == "" {
+        requester = c.ID
+    }
+    now, err := txTimestamp(ctx)
+    if err != nil {
+        return nil, err
+    }
+    txID := ctx.GetStub().GetTxID()
+
+    req := AccessRequest{
+        DocType:       "accessRequest",
+        RequestID:     txID,
+        ResourceType:  resourceType,
+        ResourceID:    resourceId,
+        OwnerOrg:      ownerOrg,
+        Requester:     requester,
+        RequesterMSP:  c.MSPID,
+        Justification: justification,
+        Status:        AccessRequestPending,
+        RequestedAt:   now,
+        Steps: []AccessRequestStep{{
+            Status: AccessRequestPending,
+            Reason: justification,
+            By:     c.ID,
+            ByMSP:  c.MSPID,
+            At:     now,
+            TxID:   txID,
+        }},
+    }
 
-        // Check access using simplified policy: require org AND role to match allowed lists
-        orgAllowed := false
-        roleAllowed := false
+    if err := putAccessRequest(ctx, &req); err != nil {
+        return nil, err
+    }
+    indexKey, err := ctx.GetStub().CreateCompositeKey(accessRequestIndex, []string{resourceId, req.RequestID})
+    if err != nil {
+        return nil, fmt.Errorf("failed to create index key: %v", err)
+    }
+    if err := ctx.GetStub().PutState(indexKey, []byte{0x00}); err != nil {
+        return nil, fmt.Errorf("failed to write index: %v", err)
+    }
+    if err := emitEvent(ctx, EventAccessRequested, accessRequestEventPayload(ctx, &req)); err != nil {
+        return nil, err
+    }
+    return &req, nil
+}
 
-        for _, o := range policy.AllowedOrgs {
-            if o == clientMSPID || o == "*" {
-                orgAllowed = true
-                break
-            }
-        }
+// ApproveAccessRequest approves a pending request and grants the requester access to the resource
+// until expiresAt (RFC3339). Only an admin of the owning organization may approve.
+func (s *SmartContract) ApproveAccessRequest(ctx contractapi.TransactionContextInterface, requestId, expiresAt string) error {
+    req, err := s.decideAccessRequest(ctx, requestId)
+    if err != nil {
+        return err
+    }
 
-        for _, r := range policy.AllowedRoles {
-            if r == userRole || r == "*" {
-                roleAllowed = true
-                break
-            }
-        }
+    grant := AccessGrant{Grantee: req.Requester, GrantedByMSP: req.OwnerOrg, RequestID: req.RequestID}
+    index := recordGrantIndex
+    if req.ResourceType == "case" {
+        grant.CaseID = req.ResourceID
+        index = caseGrantIndex
+    } else {
+        grant.RecordID = req.ResourceID
+    }
+    if err := putAccessGrant(ctx, index, req.ResourceID, &grant, expiresAt); err != nil {
+        return err
+    }
 
-        if !(orgAllowed && roleAllowed) {
-            return nil, fmt.Errorf("access denied by policy for organization %s and role %s", clientMSPID, userRole)
-        }
+    req.ExpiresAt = grant.ExpiresAt
+    if err := appendAccessRequestStep(ctx, req, AccessRequestApproved, ""); err != nil {
+        return err
     }
+    return emitEvent(ctx, EventAccessRequestApproved, accessRequestEventPayload(ctx, req))
+}
 
-    return &caseObj, nil
+// RejectAccessRequest rejects a pending request. Only an admin of the owning organization may reject,
+// and a reason is required.
+func (s *SmartContract) RejectAccessRequest(ctx contractapi.TransactionContextInterface, requestId, reason string) error {
+    if reason == "" {
+        return fmt.Errorf("a reason is required to reject an access request")
+    }
+    req, err := s.decideAccessRequest(ctx, requestId)
+    if err != nil {
+        return err
+    }
+    if err := appendAccessRequestStep(ctx, req, AccessRequestRejected, reason); err != nil {
+        return err
+    }
+    return emitEvent(ctx, EventAccessRequestRejected, accessRequestEventPayload(ctx, req))
 }
 
-// QueryAllCases supports optional rich query passed as filters JSON. If filters is empty, returns all cases.
-func (s *SmartContract) QueryAllCases(ctx contractapi.TransactionContextInterface, filters string, userRole string) ([]*Case, error) {
-    // --- FIX for LevelDB ---
-    // GetStateByRange does not support filters. We will get all cases.
-    // A warning is logged if filters were provided, as they will be ignored.
-    if filters != "" {
-        log.Printf("Warning: QueryAllCases filters are ignored due to LevelDB limitations. Returning all cases.")
+// QueryAccessRequest returns a request to its requester or to an admin of the organization that owns the
+// resource now. For a resource that no longer exists, the owner recorded on the request is used.
+func (s *SmartContract) QueryAccessRequest(ctx contractapi.TransactionContextInterface, requestId string) (*AccessRequest, error) {
+    req, err := readAccessRequest(ctx, requestId)
+    if err != nil {
+        return nil, err
     }
-    
-    startKey := "case:"
-    endKey := "case:\uffff"
+    c, err := s.getCaller(ctx)
+    if err != nil {
+        return nil, err
+    }
+    if (req.Requester == c.Username || req.Requester == c.ID) && req.RequesterMSP == c.MSPID {
+        return req, nil
+    }
+    ownerOrg := req.OwnerOrg
+    if _, current, err := s.resolveAccessResource(ctx, req.ResourceID); err == nil {
+        ownerOrg = current
+    }
+    if err := s.requireOrgAdmin(ctx, ownerOrg); err != nil {
+        return nil, err
+    }
+    return req, nil
+}
 
-    resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, endKey)
+// QueryAccessRequests lists every request filed against a record or case, in any status.
+// Only an admin of the owning organization may list them.
+func (s *SmartContract) QueryAccessRequests(ctx contractapi.TransactionContextInterface, resourceId string) ([]*AccessRequest, error) {
+    _, ownerOrg, err := s.resolveAccessResource(ctx, resourceId)
     if err != nil {
-        return nil, fmt.Errorf("failed to execute case query: %v", err)
+        return nil, err
+    }
+    if err := s.requireOrgAdmin(ctx, ownerOrg); err != nil {
+        return nil, err
     }
-    defer resultsIterator.Close()
 
-    clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+    resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(accessRequestIndex, []string{resourceId})
     if err != nil {
-        return nil, fmt.Errorf("failed to get client MSP ID: %v", err)
+        return nil, fmt.Errorf("failed to query access requests: %v", err)
     }
+    defer resultsIterator.Close()
 
-    var cases []*Case
+    var requests []*AccessRequest
     for resultsIterator.HasNext() {
         qr, err := resultsIterator.Next()
         if err != nil {
             return nil, err
         }
-        var c Case
-        if err := json.Unmarshal(qr.Value, &c); err != nil {
+        _, keyParts, err := ctx.GetStub().SplitCompositeKey(qr.Key)
+        if err != nil {
+            return nil, fmt.Errorf("failed to split index key %s: %v", qr.Key, err)
+        }
+        if len(keyParts) < 2 {
+            continue
+        }
+        req, err := readAccessRequest(ctx, keyParts[1])
+        if err != nil {
             return nil, err
         }
-        
-        if c.DocType == "case" {
-            // If case has no policy, include it
-            if c.PolicyID == "" {
-                cases = append(cases, &c)
-                continue
-            }
-
-            // Check policy access
-            policy, err := s.QueryPolicy(ctx, c.PolicyID)
-            if err != nil {
-                log.Printf("Warning: Could not check policy %s for case %s: %v", c.PolicyID, c.ID, err)
-                continue
-            }
-
-            // Check access using simplified policy
-            orgAllowed := false
-            roleAllowed := false
-
-            for _, o := range policy.AllowedOrgs {
-                if o == clientMSPID || o == "*" {
-                    orgAllowed = true
-                    break
-                }
-            }
-
-            for _, r := range policy.AllowedRoles {
-                if r == userRole || r == "*" {
-                    roleAllowed = true
-                    break
-                }
-            }
+        requests = append(requests, req)
+    }
+    return requests, nil
+}
 
-            if orgAllowed && roleAllowed {
-                cases = append(cases, &c)
-            }
+// resolveAccessResource finds the record or case with the given ID and returns its type and owning organization.
+func (s *SmartContract) resolveAccessResource(ctx contractapi.TransactionContextInterface, resourceId string) (string, string, error) {
+    recJSON, err := ctx.GetStub().GetState("record:" + resourceId)
+    if err != nil {
+        return "", "", fmt.Errorf("failed to read record: %v", err)
+    }
+    if recJSON != nil {
+        var rec Record
+        if err := json.Unmarshal(recJSON, &rec); err != nil {
+            return "", "", err
         }
+        return "record", rec.OwnerOrg, nil
     }
-    return cases, nil
-}
 
-func (s *SmartContract) DeleteCase(ctx contractapi.TransactionContextInterface, id string) error {
-    key := "case:" + id
-    exists, err := ctx.GetStub().GetState(key)
+    caseJSON, err := ctx.GetStub().GetState("case:" + resourceId)
     if err != nil {
-        return fmt.Errorf("failed to check case: %v", err)
+        return "", "", fmt.Errorf("failed to read case: %v", err)
     }
-    if exists == nil {
-        return fmt.Errorf("case %s does not exist", id)
+    if caseJSON != nil {
+        var caseObj Case
+        if err := json.Unmarshal(caseJSON, &caseObj); err != nil {
+            return "", "", err
+        }
+        return "case", caseObj.Organization, nil
     }
-    return ctx.GetStub().DelState(key)
+    return "", "", fmt.Errorf("no record or case %s", resourceId)
 }
 
-// --------------------------- RECORDS --------------------------------
-
-// CreateRecord stores a Record. Backend should supply createdAt and ownerOrg.
-func (s *SmartContract) CreateRecord(ctx contractapi.TransactionContextInterface, id, caseId, recordType, fileHash, offChainUri, ownerOrg, createdAt, policyId, description string) error {
-    key := "record:" + id
-    exists, err := ctx.GetStub().GetState(key)
+// decideAccessRequest loads a pending request and checks that the caller is an admin of the organization
+// that owns the resource now. The owner recorded when the request was filed may have handed over custody
+// since, so req.OwnerOrg is refreshed before the decision is stored.
+func (s *SmartContract) decideAccessRequest(ctx contractapi.TransactionContextInterface, requestId string) (*AccessRequest, error) {
+    req, err := readAccessRequest(ctx, requestId)
     if err != nil {
-        return fmt.Errorf("failed to check record: %v", err)
+        return nil, err
     }
-    if exists != nil {
-        return fmt.Errorf("record %s already exists", id)
+    _, ownerOrg, err := s.resolveAccessResource(ctx, req.ResourceID)
+    if err != nil {
+        return nil, err
     }
-
-    rec := Record{
-        DocType:     "record",
-        ID:          id,
-        CaseID:      caseId,
-        RecordType:  recordType,
-        FileHash:    fileHash,
-        OffChainURI: offChainUri,
-        OwnerOrg:    ownerOrg,
-        CreatedAt:   createdAt,
-        PolicyID:    policyId,
-        Description: description,
+    if err := s.requireOrgAdmin(ctx, ownerOrg); err != nil {
+        return nil, err
+    }
+    req.OwnerOrg = ownerOrg
+    if req.Status != AccessRequestPending {
+        return nil, fmt.Errorf("access request %s is already %s", requestId, req.Status)
     }
+    return req, nil
+}
 
-    recJSON, err := json.Marshal(rec)
+func appendAccessRequestStep(ctx contractapi.TransactionContextInterface, req *AccessRequest, status, reason string) error {
+    now, clientID, err := txAuthor(ctx)
     if err != nil {
         return err
     }
-    return ctx.GetStub().PutState(key, recJSON)
+    clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+    if err != nil {
+        return fmt.Errorf("failed to get client MSP ID: %v", err)
+    }
+    req.Status = status
+    req.Steps = append(req.Steps, AccessRequestStep{
+        Status: status,
+        Reason: reason,
+        By:     clientID,
+        ByMSP:  clientMSPID,
+        At:     now,
+        TxID:   ctx.GetStub().GetTxID(),
+    })
+    return putAccessRequest(ctx, req)
 }
 
-func (s *SmartContract) QueryRecord(ctx contractapi.TransactionContextInterface, id string, userRole string) (*Record, error) {
-    key := "record:" + id
-    recJSON, err := ctx.GetStub().GetState(key)
+func readAccessRequest(ctx contractapi.TransactionContextInterface, requestId string) (*AccessRequest, error) {
+    reqJSON, err := ctx.GetStub().GetState("accessrequest:" + requestId)
     if err != nil {
-        return nil, fmt.Errorf("failed to read record: %v", err)
+        return nil, fmt.Errorf("failed to read access request: %v", err)
     }
-    if recJSON == nil {
-        return nil, fmt.Errorf("record %s not found", id)
+    if reqJSON == nil {
+        return nil, fmt.Errorf("access request %s not found", requestId)
     }
-    var rec Record
-    if err := json.Unmarshal(recJSON, &rec); err != nil {
+    var req AccessRequest
+    if err := json.Unmarshal(reqJSON, &req); err != nil {
         return nil, err
     }
+    return &req, nil
+}
 
-    // Get client org and role for policy check
-    clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+func putAccessRequest(ctx contractapi.TransactionContextInterface, req *AccessRequest) error {
+    reqJSON, err := json.Marshal(req)
     if err != nil {
-        return nil, fmt.Errorf("failed to get client MSP ID: %v", err)
+        return err
     }
+    return ctx.GetStub().PutState("accessrequest:"+req.RequestID, reqJSON)
+}
 
-    // Get the policy
-    if rec.PolicyID == "" {
-        return nil, fmt.Errorf("record %s has no associated policy", id)
+func accessRequestEventPayload(ctx contractapi.TransactionContextInterface, req *AccessRequest) AccessRequestEventPayload {
+    return AccessRequestEventPayload{
+        RequestID:    req.RequestID,
+        ResourceType: req.ResourceType,
+        ResourceID:   req.ResourceID,
+        OwnerOrg:     req.OwnerOrg,
+        Requester:    req.Requester,
+        Status:       req.Status,
+        ExpiresAt:    req.ExpiresAt,
+        TxID:         ctx.GetStub().GetTxID(),
     }
+}
 
-    policy, err := s.QueryPolicy(ctx, rec.PolicyID)
-    if err != nil {
-        return nil, fmt.Errorf("failed to get policy %s: %v", rec.PolicyID, err)
-    }
+// --------------------------- AUDIT ----------------------------------
+// AccessRecord and AccessCase are submitted (not evaluated) so that every read, allowed or not, leaves an
+// AuditEntry on the ledger under audit~resource composite keys of [resourceId, txId]. A denial is returned
+// in the result instead of as an error, so the transaction still commits and the denial is kept.
 
-    // Check access using simplified policy: require org AND role to match allowed lists
-    orgAllowed := false
-    roleAllowed := false
+const auditIndex = "audit~resource"
 
-    for _, o := range policy.AllowedOrgs {
-        if o == clientMSPID || o == "*" {
-            orgAllowed = true
-            break
-        }
+// Audit decisions
+const (
+    AuditDecisionAllowed = "allowed"
+    AuditDecisionDenied  = "denied"
+)
+
+// Audit actions
+const (
+    auditActionRead   = "read"
+    auditActionVerify = "verify"
+)
+
+// RecordAccessResult is returned by AccessRecord. Record is nil when access was denied.
+type RecordAccessResult struct {
+    Decision string  `json:"decision"`
+    Reason   string  `json:"reason,omitempty"`
+    Record   *Record `json:"record,omitempty"`
+}
+
+// CaseAccessResult is returned by AccessCase. Case is nil when access was denied.
+type CaseAccessResult struct {
+    Decision string `json:"decision"`
+    Reason   string `json:"reason,omitempty"`
+    Case     *Case  `json:"case,omitempty"`
+}
+
+// AccessRecord applies the same policy check as QueryRecord and writes an audit entry for the read.
+// The private description is not returned: submit responses are written to the block, so clients
+// that need it should follow up with QueryRecord.
+func (s *SmartContract) AccessRecord(ctx contractapi.TransactionContextInterface, id string) (*RecordAccessResult, error) {
+    rec, err := s.readRecord(ctx, id)
+    if err != nil {
+        return nil, err
     }
 
-    for _, r := range policy.AllowedRoles {
-        if r == userRole || r == "*" {
-            roleAllowed = true
-            break
-        }
+    accessErr := s.checkRecordAccess(ctx, rec)
+    entry, err := s.writeAuditEntry(ctx, auditActionRead, "record", id, "", accessErr)
+    if err != nil {
+        return nil, err
+    }
+    if accessErr != nil {
+        return &RecordAccessResult{Decision: entry.Decision, Reason: entry.Reason}, nil
     }
+    return &RecordAccessResult{Decision: entry.Decision, Record: rec}, nil
+}
 
-    if !(orgAllowed && roleAllowed) {
-        return nil, fmt.Errorf("access denied by policy for organization %s and role %s", clientMSPID, userRole)
+// AccessCase applies the same policy check as QueryCase and writes an audit entry for the read.
+// As with AccessRecord, the private description is not returned.
+func (s *SmartContract) AccessCase(ctx contractapi.TransactionContextInterface, id string) (*CaseAccessResult, error) {
+    caseObj, err := s.readCase(ctx, id)
+    if err != nil {
+        return nil, err
     }
 
-    return &rec, nil
+    accessErr := s.checkCaseAccess(ctx, caseObj)
+    entry, err := s.writeAuditEntry(ctx, auditActionRead, "case", id, "", accessErr)
+    if err != nil {
+        return nil, err
+    }
+    if accessErr != nil {
+        return &CaseAccessResult{Decision: entry.Decision, Reason: entry.Reason}, nil
+    }
+    return &CaseAccessResult{Decision: entry.Decision, Case: caseObj}, nil
 }
 
-// QueryRecordsByCase returns records belonging to a case
-func (s *SmartContract) QueryRecordsByCase(ctx contractapi.TransactionContextInterface, caseId string) ([]*Record, error) {
-    // --- FIX for LevelDB ---
-    // We must get all records and filter them in-memory
-    startKey := "record:"
-    endKey := "record:\uffff"
-
-    resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, endKey)
+// QueryAuditTrail returns the audit entries of a record or case, oldest first.
+// Only an admin of the organization owning the resource may read its trail.
+func (s *SmartContract) QueryAuditTrail(ctx contractapi.TransactionContextInterface, resourceId string) ([]*AuditEntry, error) {
+    _, ownerOrg, err := s.resolveAccessResource(ctx, resourceId)
     if err != nil {
-        return nil, fmt.Errorf("failed to execute query: %v", err)
+        return nil, err
+    }
+    if err := s.requireOrgAdmin(ctx, ownerOrg); err != nil {
+        return nil, err
     }
-    defer resultsIterator.Close()
 
-    clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+    resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(auditIndex, []string{resourceId})
     if err != nil {
-        return nil, fmt.Errorf("failed to get client MSP ID: %v", err)
+        return nil, fmt.Errorf("failed to query audit trail: %v", err)
     }
+    defer resultsIterator.Close()
 
-    var records []*Record
+    var entries []*AuditEntry
     for resultsIterator.HasNext() {
         qr, err := resultsIterator.Next()
         if err != nil {
             return nil, err
         }
-        var r Record
-        if err := json.Unmarshal(qr.Value, &r); err != nil {
+        var entry AuditEntry
+        if err := json.Unmarshal(qr.Value, &entry); err != nil {
             return nil, err
         }
-
-        // Manual in-memory filtering
-        if r.DocType == "record" && r.CaseID == caseId {
-            // Check policy for each record
-            if r.PolicyID == "" {
-                continue // Skip records without policy
-            }
-
-            policy, err := s.QueryPolicy(ctx, r.PolicyID)
-            if err != nil {
-                continue // Skip if policy can't be retrieved
-            }
-
-            // Check if org is allowed by simplified policy (role not available here)
-            hasAccess := false
-            for _, o := range policy.AllowedOrgs {
-                if o == clientMSPID || o == "*" {
-                    hasAccess = true
-                    break
-                }
-            }
-
-            if hasAccess {
-                records = append(records, &r)
-            }
-        }
+        entries = append(entries, &entry)
     }
-    return records, nil
+    sort.SliceStable(entries, func(i, j int) bool { return entries[i].Timestamp < entries[j].Timestamp })
+    return entries, nil
 }
 
-// QueryRecords executes a rich query supplied by backend (searchJSON must be a selector query)
-func (s *SmartContract) QueryRecords(ctx contractapi.TransactionContextInterface, searchJSON string) ([]*Record, error) {
-    // --- FIX for LevelDB ---
-    // GetStateByRange does not support filters. We will get all records.
-    // A warning is logged if searchJSON was provided, as it will be ignored.
-    if searchJSON != "" {
-         log.Printf("Warning: QueryRecords searchJSON is ignored due to LevelDB limitations. Returning all records.")
+// writeAuditEntry stores the outcome of an access check and emits ResourceAccessed. If the caller
+// cannot be resolved (e.g. a suspended user) the raw client identity is recorded instead.
+func (s *SmartContract) writeAuditEntry(ctx contractapi.TransactionContextInterface, action, resourceType, resourceId, detail string, accessErr error) (*AuditEntry, error) {
+    now, err := txTimestamp(ctx)
+    if err != nil {
+        return nil, err
     }
 
-    startKey := "record:"
-    endKey := "record:\uffff"
+    entry := AuditEntry{
+        DocType:      "audit",
+        ResourceType: resourceType,
+        ResourceID:   resourceId,
+        Action:       action,
+        Detail:       detail,
+        TxID:         ctx.GetStub().GetTxID(),
+        Timestamp:    now,
+        Decision:     AuditDecisionAllowed,
+    }
+    if accessErr != nil {
+        entry.Decision = AuditDecisionDenied
+        entry.Reason = accessErr.Error()
+    }
 
-    resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, endKey)
+    if c, err := s.getCaller(ctx); err == nil {
+        entry.CallerID, entry.MSPID, entry.Role, entry.Username = c.ID, c.MSPID, c.Role, c.Username
+    } else {
+        entry.CallerID, _ = ctx.GetClientIdentity().GetID()
+        entry.MSPID, _ = ctx.GetClientIdentity().GetMSPID()
+    }
+
+    auditKey, err := ctx.GetStub().CreateCompositeKey(auditIndex, []string{resourceId, entry.TxID})
     if err != nil {
-        return nil, fmt.Errorf("failed to execute record query: %v", err)
+        return nil, fmt.Errorf("failed to create audit key: %v", err)
     }
-    defer resultsIterator.Close()
+    entryJSON, err := json.Marshal(entry)
+    if err != nil {
+        return nil, err
+    }
+    if err := ctx.GetStub().PutState(auditKey, entryJSON); err != nil {
+        return nil, fmt.Errorf("failed to write audit entry: %v", err)
+    }
+    // The event leaves out the caller's DN and the detail text; those stay in the audit entry
+    if err := emitEvent(ctx, EventResourceAccessed, AuditEventPayload{
+        ResourceType: entry.ResourceType,
+        ResourceID:   entry.ResourceID,
+        Action:       entry.Action,
+        Decision:     entry.Decision,
+        MSPID:        entry.MSPID,
+        TxID:         entry.TxID,
+    }); err != nil {
+        return nil, err
+    }
+    return &entry, nil
+}
 
-    clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+// --------------------------- HISTORY --------------------------------
+// History requires the peer to run with the history database enabled (core.ledger.history.enableHistoryDatabase).
+
+// GetCaseHistory returns every committed version of a case, newest first. The caller must pass the same
+// policy check as QueryCase. For a deleted case the check runs against its last version before deletion.
+func (s *SmartContract) GetCaseHistory(ctx contractapi.TransactionContextInterface, id string) ([]*CaseHistoryEntry, error) {
+    resultsIterator, err := ctx.GetStub().GetHistoryForKey("case:" + id)
     if err != nil {
-        return nil, fmt.Errorf("failed to get client MSP ID: %v", err)
+        return nil, fmt.Errorf("failed to get history for case %s: %v", id, err)
     }
+    defer resultsIterator.Close()
 
-    var records []*Record
+    var history []*CaseHistoryEntry
+    var lastCase *Case
     for resultsIterator.HasNext() {
-        qr, err := resultsIterator.Next()
+        km, err := resultsIterator.Next()
         if err != nil {
             return nil, err
         }
-        var r Record
-        if err := json.Unmarshal(qr.Value, &r); err != nil {
-            return nil, err
+        entry := &CaseHistoryEntry{
+            TxID:      km.TxId,
+            Timestamp: km.Timestamp.AsTime().UTC().Format(time.RFC3339),
+            IsDelete:  km.IsDelete,
         }
-        
-        if r.DocType == "record" {
-            // Check policy for each record
-            if r.PolicyID == "" {
-                continue // Skip records without policy
-            }
-
-            policy, err := s.QueryPolicy(ctx, r.PolicyID)
-            if err != nil {
-                continue // Skip if policy can't be retrieved
-            }
-
-            // Check if org is allowed by simplified policy (role not available here)
-            hasAccess := false
-            for _, o := range policy.AllowedOrgs {
-                if o == clientMSPID || o == "*" {
-                    hasAccess = true
-                    break
-                }
+        if !km.IsDelete {
+            var c Case
+            if err := json.Unmarshal(km.Value, &c); err != nil {
+                return nil, err
             }
-
-            if hasAccess {
-                records = append(records, &r)
+            entry.Value = &c
+            if lastCase == nil {
+                lastCase = &c // History is newest first
             }
         }
+        history = append(history, entry)
     }
-    return records, nil
-}
 
-// UpdateRecordMetadata accepts a JSON map of fields to update for a record.
-func (s *SmartContract) UpdateRecordMetadata(ctx contractapi.TransactionContextInterface, id string, metadataJSON string) error {
-    key := "record:" + id
-    recJSON, err := ctx.GetStub().GetState(key)
+    caseObj, err := s.readCase(ctx, id)
     if err != nil {
-        return fmt.Errorf("failed to read record: %v", err)
-    }
-    if recJSON == nil {
-        return fmt.Errorf("record %s not found", id)
+        if lastCase == nil {
+            return nil, err
+        }
+        caseObj = lastCase // Deleted: check against the last version that existed
     }
-
-    var rec Record
-    if err := json.Unmarshal(recJSON, &rec); err != nil {
-        return err
+    if err := s.checkCaseAccess(ctx, caseObj); err != nil {
+        return nil, err
     }
+    return history, nil
+}
 
-    var updates map[string]interface{}
-    if err := json.Unmarshal([]byte(metadataJSON), &updates); err != nil {
-        return fmt.Errorf("invalid metadata JSON: %v", err)
+// GetRecordHistory returns every committed version of a record, newest first. The caller must pass the same
+// policy check as QueryRecord. For a deleted record the check runs against its last version before deletion.
+func (s *SmartContract) GetRecordHistory(ctx contractapi.TransactionContextInterface, id string) ([]*RecordHistoryEntry, error) {
+    resultsIterator, err := ctx.GetStub().GetHistoryForKey("record:" + id)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get history for record %s: %v", id, err)
     }
+    defer resultsIterator.Close()
 
-    if v, ok := updates["policyId"].(string); ok {
-        rec.PolicyID = v
-    }
-    if v, ok := updates["recordType"].(string); ok {
-        rec.RecordType = v
-    }
-    if v, ok := updates["ownerOrg"].(string); ok {
-        rec.OwnerOrg = v
-    }
-    if v, ok := updates["description"].(string); ok {
-        rec.Description = v
+    var history []*RecordHistoryEntry
+    var lastRecord *Record
+    for resultsIterator.HasNext() {
+        km, err := resultsIterator.Next()
+        if err != nil {
+            return nil, err
+        }
+        entry := &RecordHistoryEntry{
+            TxID:      km.TxId,
+            Timestamp: km.Timestamp.AsTime().UTC().Format(time.RFC3339),
+            IsDelete:  km.IsDelete,
+        }
+        if !km.IsDelete {
+            var r Record
+            if err := json.Unmarshal(km.Value, &r); err != nil {
+                return nil, err
+            }
+            entry.Value = &r
+            if lastRecord == nil {
+                lastRecord = &r // History is newest first
+            }
+        }
+        history = append(history, entry)
     }
-    // Accept other metadata fields as needed.
 
-    newJSON, err := json.Marshal(rec)
+    rec, err := s.readRecord(ctx, id)
     if err != nil {
-        return err
+        if lastRecord == nil {
+            return nil, err
+        }
+        rec = lastRecord // Deleted: check against the last version that existed
+    }
+    if err := s.checkRecordAccess(ctx, rec); err != nil {
+        return nil, err
     }
-    return ctx.GetStub().PutState(key, newJSON)
+    return history, nil
 }
 
 // --------------------------- MAIN ----------------------------------
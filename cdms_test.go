@@ -0,0 +1,416 @@
+package main
+
+import (
+    "crypto/sha256"
+    "crypto/x509"
+    "crypto/x509/pkix"
+    "encoding/hex"
+    "fmt"
+    "reflect"
+    "strings"
+    "testing"
+
+    "github.com/hyperledger/fabric-chaincode-go/shimtest"
+    "github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// --------------------------- TEST LEDGER ---------------------------------
+
+// testIdentity is a client identity with a fixed ID, MSP, attributes and certificate OUs.
+type testIdentity struct {
+    id    string
+    mspId string
+    attrs map[string]string
+    ous   []string
+}
+
+func (i *testIdentity) GetID() (string, error)    { return i.id, nil }
+func (i *testIdentity) GetMSPID() (string, error) { return i.mspId, nil }
+
+func (i *testIdentity) GetAttributeValue(attr string) (string, bool, error) {
+    v, ok := i.attrs[attr]
+    return v, ok, nil
+}
+
+func (i *testIdentity) AssertAttributeValue(attr, value string) error {
+    if v, ok := i.attrs[attr]; !ok || v != value {
+        return fmt.Errorf("attribute %s is not %s", attr, value)
+    }
+    return nil
+}
+
+func (i *testIdentity) GetX509Certificate() (*x509.Certificate, error) {
+    return &x509.Certificate{Subject: pkix.Name{OrganizationalUnit: i.ous}}, nil
+}
+
+var (
+    testOrg1Admin = &testIdentity{id: "x509::CN=Admin@org1", mspId: "Org1MSP", ous: []string{"admin"}}
+    testOrg2Admin = &testIdentity{id: "x509::CN=Admin@org2", mspId: "Org2MSP", ous: []string{"admin"}}
+)
+
+// testLedger is an in-memory ledger on which every call runs as its own transaction.
+type testLedger struct {
+    stub *shimtest.MockStub
+    txs  int
+}
+
+func newTestLedger() *testLedger {
+    return &testLedger{stub: shimtest.NewMockStub("cdms", nil)}
+}
+
+// as starts a transaction submitted by id. Descriptions are passed in the transient map, as the backend does.
+func (l *testLedger) as(id *testIdentity) *contractapi.TransactionContext {
+    l.txs++
+    l.stub.MockTransactionStart(fmt.Sprintf("tx%d", l.txs))
+    l.stub.TransientMap = map[string][]byte{
+        "description":     []byte("details"),
+        "descriptionSalt": []byte("0123456789abcdef"),
+    }
+    ctx := &contractapi.TransactionContext{}
+    ctx.SetStub(l.stub)
+    ctx.SetClientIdentity(id)
+    return ctx
+}
+
+// --------------------------- INTEGRITY ---------------------------------
+
+func testLeaf(data string) []byte {
+    sum := sha256.Sum256([]byte(data))
+    return sum[:]
+}
+
+func testLeafHash(leaf []byte) []byte {
+    sum := sha256.Sum256(append([]byte{merkleLeafPrefix}, leaf...))
+    return sum[:]
+}
+
+func testNodeHash(left, right []byte) []byte {
+    node := append(append([]byte{merkleNodePrefix}, left...), right...)
+    sum := sha256.Sum256(node)
+    return sum[:]
+}
+
+func TestMerkleRootFromProof(t *testing.T) {
+    a, b, c, d := testLeaf("a"), testLeaf("b"), testLeaf("c"), testLeaf("d")
+    ab := testNodeHash(testLeafHash(a), testLeafHash(b))
+    cd := testNodeHash(testLeafHash(c), testLeafHash(d))
+    root4 := hex.EncodeToString(testNodeHash(ab, cd))
+    root2 := hex.EncodeToString(ab)
+    root1 := hex.EncodeToString(testLeafHash(a))
+
+    step := func(h []byte, position string) MerkleProofStep {
+        return MerkleProofStep{Hash: hex.EncodeToString(h), Position: position}
+    }
+
+    tests := []struct {
+        name      string
+        leaf      []byte
+        proof     []MerkleProofStep
+        leafCount int
+        wantRoot  string
+        wantErr   string
+    }{
+        {"single leaf", a, nil, 1, root1, ""},
+        {"left leaf of two", a, []MerkleProofStep{step(testLeafHash(b), "right")}, 2, root2, ""},
+        {"right leaf of two", b, []MerkleProofStep{step(testLeafHash(a), "left")}, 2, root2, ""},
+        {"third leaf of four", c, []MerkleProofStep{step(testLeafHash(d), "right"), step(ab, "left")}, 4, root4, ""},
+        {"fourth leaf of four", d, []MerkleProofStep{step(testLeafHash(c), "left"), step(ab, "left")}, 4, root4, ""},
+        // Without domain separation the inner node ab would verify as a leaf of the four-leaf tree
+        {"inner node as leaf", ab, []MerkleProofStep{step(cd, "right")}, 4, "", ""},
+        {"proof longer than tree depth", a, []MerkleProofStep{step(testLeafHash(b), "right"), step(cd, "right")}, 2, "", "at most 1"},
+        {"proof for single leaf", a, []MerkleProofStep{step(testLeafHash(b), "right")}, 1, "", "at most 0"},
+        {"bad position", a, []MerkleProofStep{step(testLeafHash(b), "up")}, 2, "", "invalid position"},
+        {"short sibling hash", a, []MerkleProofStep{{Hash: "abcd", Position: "right"}}, 2, "", "invalid hash"},
+        {"zero leaf count", a, nil, 0, "", "leafCount must be positive"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            root, err := merkleRootFromProof(hex.EncodeToString(tt.leaf), tt.proof, tt.leafCount)
+            if tt.wantErr != "" {
+                if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                    t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("unexpected error: %v", err)
+            }
+            if tt.wantRoot == "" {
+                if root == root4 {
+                    t.Fatalf("proof for an inner node reproduced the root")
+                }
+                return
+            }
+            if root != tt.wantRoot {
+                t.Fatalf("got root %s, want %s", root, tt.wantRoot)
+            }
+        })
+    }
+}
+
+func TestMerkleRootFromProofRejectsBadLeafHash(t *testing.T) {
+    if _, err := merkleRootFromProof("not-hex", nil, 1); err == nil {
+        t.Fatal("expected an error for a non-hex leaf hash")
+    }
+}
+
+// --------------------------- POLICIES ---------------------------------
+
+func TestEvaluatePolicy(t *testing.T) {
+    open := &Policy{PolicyID: "open", AllowedOrgs: []string{"*"}, AllowedRoles: []string{"*"}}
+    restricted := &Policy{
+        PolicyID:     "restricted",
+        AllowedOrgs:  []string{"Org1MSP", "Org2MSP"},
+        AllowedRoles: []string{"investigator", "judge"},
+        DeniedOrgs:   []string{"Org2MSP"},
+        DeniedRoles:  []string{"judge"},
+        DeniedUsers:  []string{"mallory", "x509::CN=eve"},
+    }
+    denyAll := &Policy{PolicyID: "deny-all", AllowedOrgs: []string{"*"}, AllowedRoles: []string{"*"}, DeniedOrgs: []string{"*"}}
+
+    tests := []struct {
+        name    string
+        policy  *Policy
+        caller  caller
+        wantErr string
+    }{
+        {"wildcard allows anyone", open, caller{ID: "x509::CN=a", MSPID: "Org9MSP", Role: "clerk"}, ""},
+        {"allowed org and role", restricted, caller{ID: "x509::CN=a", MSPID: "Org1MSP", Role: "investigator"}, ""},
+        {"org not allowed", restricted, caller{ID: "x509::CN=a", MSPID: "Org3MSP", Role: "investigator"}, "organization Org3MSP and role investigator"},
+        {"role not allowed", restricted, caller{ID: "x509::CN=a", MSPID: "Org1MSP", Role: "clerk"}, "organization Org1MSP and role clerk"},
+        {"missing role", restricted, caller{ID: "x509::CN=a", MSPID: "Org1MSP"}, "access denied by policy"},
+        {"denied org overrides allow", restricted, caller{ID: "x509::CN=a", MSPID: "Org2MSP", Role: "investigator"}, "organization Org2MSP is denied"},
+        {"denied role overrides allow", restricted, caller{ID: "x509::CN=a", MSPID: "Org1MSP", Role: "judge"}, "role judge is denied"},
+        {"denied username", restricted, caller{ID: "x509::CN=m", MSPID: "Org1MSP", Role: "investigator", Username: "mallory"}, "user is denied"},
+        {"denied client identity", restricted, caller{ID: "x509::CN=eve", MSPID: "Org1MSP", Role: "investigator"}, "user is denied"},
+        {"deny wildcard overrides allow wildcard", denyAll, caller{ID: "x509::CN=a", MSPID: "Org1MSP", Role: "investigator"}, "is denied"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            err := evaluatePolicy(tt.policy, &tt.caller)
+            if tt.wantErr == "" {
+                if err != nil {
+                    t.Fatalf("unexpected error: %v", err)
+                }
+                return
+            }
+            if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+            }
+        })
+    }
+}
+
+func TestPolicyDenialIgnoresAllowLists(t *testing.T) {
+    policy := &Policy{PolicyID: "p", DeniedRoles: []string{"clerk"}}
+    if err := policyDenial(policy, &caller{ID: "x509::CN=a", MSPID: "Org1MSP", Role: "investigator"}); err != nil {
+        t.Fatalf("caller outside the deny lists was denied: %v", err)
+    }
+    if err := policyDenial(policy, &caller{ID: "x509::CN=a", MSPID: "Org1MSP", Role: "clerk"}); err == nil {
+        t.Fatal("caller with a denied role was not denied")
+    }
+    // An empty username must not match a deny entry by accident
+    if err := policyDenial(&Policy{PolicyID: "p", DeniedUsers: []string{""}}, &caller{ID: "x509::CN=a"}); err != nil {
+        t.Fatalf("empty username matched a deny entry: %v", err)
+    }
+}
+
+// --------------------------- FILTERS ---------------------------------
+
+type filterTest struct {
+    name    string
+    filters string
+    want    bool
+    wantErr bool
+}
+
+// testMatches runs matchesFilters over doc for each named filters JSON.
+func testMatches(t *testing.T, doc map[string]interface{}, tests []filterTest) {
+    t.Helper()
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            filters, err := parseFilters(tt.filters)
+            if err != nil {
+                t.Fatalf("parseFilters: %v", err)
+            }
+            got, err := matchesFilters(doc, filters)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("got error %v, wantErr %v", err, tt.wantErr)
+            }
+            if got != tt.want {
+                t.Fatalf("got %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestMatchesFiltersOnCases(t *testing.T) {
+    doc, err := toDocument(&Case{ID: "case-1", Title: "The burglary", Status: CaseStatusOpen, Jurisdiction: "North"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    testMatches(t, doc, []filterTest{
+        {"no filters", ``, true, false},
+        {"empty object", `{}`, true, false},
+        {"equality", `{"status":"Open"}`, true, false},
+        {"equality mismatch", `{"status":"Closed"}`, false, false},
+        {"unset filters ignored", `{"status":"","jurisdiction":null}`, true, false},
+        {"$eq", `{"jurisdiction":{"$eq":"North"}}`, true, false},
+        {"$in", `{"jurisdiction":{"$in":["South","North"]}}`, true, false},
+        {"$in mismatch", `{"jurisdiction":{"$in":["South","East"]}}`, false, false},
+        {"$prefix", `{"title":{"$prefix":"The "}}`, true, false},
+        {"$prefix is case sensitive", `{"title":{"$prefix":"the"}}`, false, false},
+        {"$prefix on missing field", `{"caseType":{"$prefix":"x"}}`, false, false},
+        {"$in without array", `{"status":{"$in":"Open"}}`, false, true},
+        {"$prefix without string", `{"title":{"$prefix":1}}`, false, true},
+        {"unknown operator", `{"title":{"$regex":"The"}}`, false, true},
+    })
+}
+
+func TestMatchesFiltersOnRecords(t *testing.T) {
+    doc, err := toDocument(&Record{ID: "rec-42", CaseID: "case-1", RecordType: "FIR", CreatedAt: "2024-03-05T10:00:00Z", Version: 3})
+    if err != nil {
+        t.Fatal(err)
+    }
+    testMatches(t, doc, []filterTest{
+        {"date range", `{"createdAt":{"$gte":"2024-03-01","$lte":"2024-03-31"}}`, true, false},
+        {"date before range", `{"createdAt":{"$gt":"2024-04-01"}}`, false, false},
+        {"number comparison", `{"version":{"$gt":3}}`, false, false},
+        {"number bound", `{"version":{"$gte":3,"$lt":4}}`, true, false},
+        {"string against number", `{"version":{"$gt":"1"}}`, false, false},
+        {"$or", `{"$or":[{"caseId":"case-2"},{"version":3}]}`, true, false},
+        {"$or none match", `{"$or":[{"caseId":"case-2"},{"version":4}]}`, false, false},
+        {"$and", `{"$and":[{"caseId":"case-1"},{"version":{"$lt":3}}]}`, false, false},
+        {"nested $or in $and", `{"$and":[{"recordType":"FIR"},{"$or":[{"caseId":"case-1"},{"caseId":"case-2"}]}]}`, true, false},
+        {"empty $and", `{"$and":[]}`, true, false},
+        {"empty $or", `{"$or":[]}`, false, false},
+        {"$or without array", `{"$or":{"caseId":"case-1"}}`, false, true},
+    })
+}
+
+func TestBuildRecordSelector(t *testing.T) {
+    selector, err := buildRecordSelector(`{"caseId":"case-1","recordType":"","dateFrom":"2024-03-01","dateTo":"2024-03-31","orgMspId":"Org1MSP"}`)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := map[string]interface{}{
+        "docType":   "record",
+        "caseId":    "case-1",
+        "createdAt": map[string]interface{}{"$gte": "2024-03-01", "$lte": "2024-03-31T23:59:59.999999999Z"},
+    }
+    if !reflect.DeepEqual(selector, want) {
+        t.Fatalf("got %v, want %v", selector, want)
+    }
+
+    selector, err = buildRecordSelector(`{"selector":{"recordType":{"$in":["FIR"]}},"caseId":"ignored"}`)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want = map[string]interface{}{"docType": "record", "recordType": map[string]interface{}{"$in": []interface{}{"FIR"}}}
+    if !reflect.DeepEqual(selector, want) {
+        t.Fatalf("got %v, want %v", selector, want)
+    }
+
+    if _, err := buildRecordSelector(`{"uploaderId":"u1"}`); err == nil {
+        t.Fatal("uploaderId was accepted although records do not store their uploader")
+    }
+}
+
+func TestCouchSelector(t *testing.T) {
+    filters, err := parseFilters(`{"id":{"$prefix":"a.b*"},"$or":[{"caseId":{"$prefix":"case-"}},{"recordType":"FIR"}],"version":{"$gt":1}}`)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := map[string]interface{}{
+        "id": map[string]interface{}{"$regex": `^a\.b\*`},
+        "$or": []interface{}{
+            map[string]interface{}{"caseId": map[string]interface{}{"$regex": "^case-"}},
+            map[string]interface{}{"recordType": "FIR"},
+        },
+        "version": map[string]interface{}{"$gt": float64(1)},
+    }
+    if got := couchSelector(filters); !reflect.DeepEqual(got, want) {
+        t.Fatalf("got %v, want %v", got, want)
+    }
+}
+
+// --------------------------- CASES ---------------------------------
+
+func TestCaseStatusLifecycle(t *testing.T) {
+    s := &SmartContract{}
+    ledger := newTestLedger()
+    for _, id := range []string{"c1", "c2"} {
+        if err := s.CreateCase(ledger.as(testOrg1Admin), id, "Burglary", "", "North", "Theft", ""); err != nil {
+            t.Fatalf("CreateCase %s: %v", id, err)
+        }
+    }
+    if err := s.ArchiveCase(ledger.as(testOrg1Admin), "c2", "no further action"); err != nil {
+        t.Fatalf("ArchiveCase: %v", err)
+    }
+
+    reopen := "reopen"
+    steps := []struct {
+        name    string
+        caller  *testIdentity
+        caseId  string
+        status  string // reopen calls ReopenCase
+        reason  string
+        wantErr string
+    }{
+        {"other organization", testOrg2Admin, "c1", CaseStatusUnderInvestigation, "new lead", "cannot change the status"},
+        {"missing reason", testOrg1Admin, "c1", CaseStatusUnderInvestigation, "", "a reason is required"},
+        {"unknown status", testOrg1Admin, "c1", "Pending", "new lead", "invalid case status"},
+        {"same status", testOrg1Admin, "c1", CaseStatusOpen, "new lead", "already Open"},
+        {"archive through status change", testOrg1Admin, "c1", CaseStatusArchived, "done", "illegal status transition"},
+        {"open to under investigation", testOrg1Admin, "c1", CaseStatusUnderInvestigation, "new lead", ""},
+        {"under investigation to closed", testOrg1Admin, "c1", CaseStatusClosed, "solved", ""},
+        {"closed to open needs ReopenCase", testOrg1Admin, "c1", CaseStatusOpen, "appeal", "use ReopenCase"},
+        {"closed to under investigation", testOrg1Admin, "c1", CaseStatusUnderInvestigation, "appeal", "illegal status transition"},
+        {"reopen without reason", testOrg1Admin, "c1", reopen, "", "a reason is required"},
+        {"reopen by other organization", testOrg2Admin, "c1", reopen, "appeal", "cannot change the status"},
+        {"reopen", testOrg1Admin, "c1", reopen, "appeal", ""},
+        {"reopen an open case", testOrg1Admin, "c1", reopen, "appeal", "only closed cases"},
+        {"archived case", testOrg1Admin, "c2", CaseStatusOpen, "new lead", "illegal status transition"},
+        {"reopen an archived case", testOrg1Admin, "c2", reopen, "new lead", "only closed cases"},
+    }
+
+    for _, step := range steps {
+        var err error
+        if step.status == reopen {
+            err = s.ReopenCase(ledger.as(step.caller), step.caseId, step.reason)
+        } else {
+            err = s.UpdateCaseStatus(ledger.as(step.caller), step.caseId, step.status, step.reason)
+        }
+        if step.wantErr == "" && err != nil {
+            t.Fatalf("%s: unexpected error: %v", step.name, err)
+        }
+        if step.wantErr != "" && (err == nil || !strings.Contains(err.Error(), step.wantErr)) {
+            t.Fatalf("%s: got error %v, want one containing %q", step.name, err, step.wantErr)
+        }
+    }
+
+    caseObj, err := s.readCase(ledger.as(testOrg1Admin), "c1")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if caseObj.Status != CaseStatusOpen {
+        t.Fatalf("got status %s, want Open", caseObj.Status)
+    }
+    var got []string
+    for _, change := range caseObj.StatusHistory {
+        if change.ChangedByMSP != "Org1MSP" || change.ChangedBy != testOrg1Admin.id {
+            t.Fatalf("status change recorded the wrong author: %+v", change)
+        }
+        got = append(got, change.From+" -> "+change.To+": "+change.Reason)
+    }
+    want := []string{
+        "Open -> Under Investigation: new lead",
+        "Under Investigation -> Closed: solved",
+        "Closed -> Open: appeal",
+    }
+    if !reflect.DeepEqual(got, want) {
+        t.Fatalf("got history %q, want %q", got, want)
+    }
+}
@@ -71,9 +71,13 @@ export class CasesController {
   @Delete(':id')
   deleteCase(
     @Param('id') id: string,
-    @Query('org') orgMspId: string
+    @Query('org') orgMspId: string,
+    @Query('reason') reason: string
   ) {
+    if (!reason) {
+      throw new BadRequestException('An archival reason (?reason=...) is required.');
+    }
     const org = normalizeOrgMspId(orgMspId);
-    return this.casesService.deleteCase(id, org as 'Org1MSP' | 'Org2MSP');
+    return this.casesService.deleteCase(id, reason, org as 'Org1MSP' | 'Org2MSP');
   }
 }
\ No newline at end of file
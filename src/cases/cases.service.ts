@@ -1,4 +1,4 @@
-import { Injectable, Logger, NotFoundException } from '@nestjs/common';
+import { ConflictException, Injectable, Logger, NotFoundException } from '@nestjs/common';
 import { FabricService } from '../records/fabric.service';
 import { CreateCaseDto } from './dto/create-case.dto';
 import { v4 as uuid } from 'uuid';
@@ -30,7 +30,7 @@ export class CasesService {
   ) {
     this.logger.log(`Getting cases as ${orgMspId} role ${userRole} with filters ${JSON.stringify(filters)}`);
     try {
-      const result = await this.fabricService.queryAllCases(JSON.stringify(filters), userRole, orgMspId);
+      const result = await this.fabricService.queryAllCases(JSON.stringify(filters), orgMspId);
       
       // Handle empty results
       if (!result || result.trim() === '') {
@@ -54,7 +54,7 @@ export class CasesService {
   async getCase(id: string, orgMspId: 'Org1MSP' | 'Org2MSP', userRole: string) {
     this.logger.log(`Getting case ${id} as ${orgMspId} role ${userRole}`);
     try {
-      const result = await this.fabricService.queryCase(id, userRole, orgMspId);
+      const result = await this.fabricService.queryCase(id, orgMspId);
       
       if (!result || result.trim() === '') {
         throw new NotFoundException(`Case ${id} not found or access denied`);
@@ -75,10 +75,19 @@ export class CasesService {
     }
   }
 
-  async deleteCase(id: string, orgMspId: 'Org1MSP' | 'Org2MSP') {
-    this.logger.log(`Deleting case ${id} as ${orgMspId}`);
-    await this.fabricService.deleteCase(id, orgMspId);
-    return { message: 'Case deleted' };
+  async deleteCase(id: string, reason: string, orgMspId: 'Org1MSP' | 'Org2MSP') {
+    this.logger.log(`Archiving case ${id} as ${orgMspId}`);
+    try {
+      await this.fabricService.archiveCase(id, reason, orgMspId);
+    } catch (error) {
+      const messages = [error.message, ...(error.details || []).map((d) => d.message)].join(' ');
+      // The chaincode refuses to archive cases with active records, an active legal hold, or already archived
+      if (/active records|legal hold|already archived/.test(messages)) {
+        throw new ConflictException(`Case ${id} cannot be archived: ${messages}`);
+      }
+      throw error;
+    }
+    return { message: 'Case archived' };
   }
 
   async verifyPolicy(policyId: string, orgMspId: 'Org1MSP' | 'Org2MSP', userRole: string) {
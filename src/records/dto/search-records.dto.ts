@@ -13,10 +13,6 @@ export class SearchRecordsDto {
   @IsString()
   recordType?: string;
 
-  @IsOptional()
-  @IsString()
-  uploaderId?: string;
-
   @IsOptional()
   @IsString()
   dateFrom?: string;
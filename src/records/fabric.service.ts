@@ -143,6 +143,14 @@ export class FabricService {
     });
   }
 
+  /**
+   * Builds the transient map for chaincode transactions that store a description in private data.
+   * The chaincode hashes the description with a secret salt, which must be random per description.
+   */
+  private descriptionTransient(description?: string): Record<string, string> {
+    return description ? { description, descriptionSalt: crypto.randomBytes(32).toString('hex') } : {};
+  }
+
   // ... (The rest of your file is PERFECT, keep createRecord, queryRecord, and createPolicy as they are) ...
 
   /**
@@ -164,9 +172,12 @@ export class FabricService {
         payload.ownerOrg,
         payload.createdAt,
         payload.policyId,
-        payload.description || '',
+        '', // description travels in the transient map so it stays out of the block
       ]);
-      await contract.submitTransaction('CreateRecord', ...txArgs);
+      await contract.submit('CreateRecord', {
+        arguments: txArgs,
+        transientData: this.descriptionTransient(payload.description),
+      });
       this.logger.log(`Transaction 'CreateRecord' committed successfully.`);
     } catch (error) {
       this.logger.error("Failed to submit 'CreateRecord' transaction", error);
@@ -252,8 +263,15 @@ export class FabricService {
     try {
       const network = gateway.getNetwork(this.channelName);
       const contract = network.getContract(this.chaincodeName);
-      const txArgs = this.prepareArgs([id, JSON.stringify(metadata)]);
-      await contract.submitTransaction('UpdateRecordMetadata', ...txArgs);
+      // A new description goes in the transient map; metadataJSON is recorded in the block
+      // An empty description only clears the stored one, so it may stay in metadataJSON
+      const { description, ...rest } = metadata ?? {};
+      const publicMetadata = description === '' ? { ...rest, description } : rest;
+      const txArgs = this.prepareArgs([id, JSON.stringify(publicMetadata)]);
+      await contract.submit('UpdateRecordMetadata', {
+        arguments: txArgs,
+        transientData: this.descriptionTransient(description),
+      });
       this.logger.log(`Transaction 'UpdateRecordMetadata' committed successfully.`);
     } catch (error) {
       this.logger.error("Failed to submit 'UpdateRecordMetadata' transaction", error);
@@ -400,8 +418,12 @@ export class FabricService {
     try {
       const network = gateway.getNetwork(this.channelName);
       const contract = network.getContract(this.chaincodeName);
-  const txArgs = this.prepareArgs([id, title, description, jurisdiction, caseType, policyId]);
-  await contract.submitTransaction('CreateCase', ...txArgs);
+      // description travels in the transient map so it stays out of the block
+      const txArgs = this.prepareArgs([id, title, '', jurisdiction, caseType, policyId]);
+      await contract.submit('CreateCase', {
+        arguments: txArgs,
+        transientData: this.descriptionTransient(description),
+      });
       this.logger.log(`Transaction 'CreateCase' committed successfully.`);
     } catch (error) {
       this.logger.error("Failed to submit 'CreateCase' transaction", error);
@@ -411,13 +433,13 @@ export class FabricService {
     }
   }
 
-  async queryRecord(id: string, orgMspId: OrgMspId, userRole?: string): Promise<string> {
-    this.logger.log(`Evaluating 'QueryRecord' as ${orgMspId} (role: ${userRole}) for ID: ${id}`);
+  async queryRecord(id: string, orgMspId: OrgMspId): Promise<string> {
+    this.logger.log(`Evaluating 'QueryRecord' as ${orgMspId} for ID: ${id}`);
     const { gateway, client } = await this.connect(orgMspId);
     try {
       const network = gateway.getNetwork(this.channelName);
       const contract = network.getContract(this.chaincodeName);
-      const evalArgs = this.prepareArgs([id, userRole || '']);
+      const evalArgs = this.prepareArgs([id]);
       const resultBytes = await contract.evaluateTransaction('QueryRecord', ...evalArgs);
       const result = Buffer.from(resultBytes).toString('utf-8');
       this.logger.log(`Transaction 'QueryRecord' evaluated successfully.`);
@@ -430,13 +452,13 @@ export class FabricService {
     }
   }
 
-  async queryAllCases(filtersJSON: string, userRole: string, orgMspId: OrgMspId): Promise<string> {
+  async queryAllCases(filtersJSON: string, orgMspId: OrgMspId): Promise<string> {
     this.logger.log(`Evaluating 'QueryAllCases' as ${orgMspId} with filters`);
     const { gateway, client } = await this.connect(orgMspId);
     try {
       const network = gateway.getNetwork(this.channelName);
       const contract = network.getContract(this.chaincodeName);
-  const evalArgs = this.prepareArgs([filtersJSON, userRole]);
+  const evalArgs = this.prepareArgs([filtersJSON]);
   const resultBytes = await contract.evaluateTransaction('QueryAllCases', ...evalArgs);
       const result = Buffer.from(resultBytes).toString('utf-8');
       this.logger.log(`Transaction 'QueryAllCases' evaluated successfully.`);
@@ -449,17 +471,18 @@ export class FabricService {
     }
   }
 
-  async deleteCase(id: string, orgMspId: OrgMspId): Promise<void> {
-    this.logger.log(`Submitting 'DeleteCase' as ${orgMspId} for ID: ${id}`);
+  async archiveCase(id: string, reason: string, orgMspId: OrgMspId): Promise<void> {
+    this.logger.log(`Submitting 'ArchiveCase' as ${orgMspId} for ID: ${id}`);
     const { gateway, client } = await this.connect(orgMspId);
     try {
       const network = gateway.getNetwork(this.channelName);
       const contract = network.getContract(this.chaincodeName);
-      const txArgs = this.prepareArgs([id]);
-      await contract.submitTransaction('DeleteCase', ...txArgs);
-      this.logger.log(`Transaction 'DeleteCase' committed successfully.`);
+      // Cases are archived rather than deleted; hard deletion needs approval from every stakeholder org and at least two orgs
+      const txArgs = this.prepareArgs([id, reason]);
+      await contract.submitTransaction('ArchiveCase', ...txArgs);
+      this.logger.log(`Transaction 'ArchiveCase' committed successfully.`);
     } catch (error) {
-      this.logger.error("Failed to submit 'DeleteCase' transaction", error);
+      this.logger.error("Failed to submit 'ArchiveCase' transaction", error);
       throw error;
     } finally {
       this.closeConnection(gateway, client);
@@ -469,13 +492,13 @@ export class FabricService {
   /**
    * Queries a case from the chaincode
    */
-  async queryCase(id: string, userRole: string, orgMspId: OrgMspId): Promise<string> {
+  async queryCase(id: string, orgMspId: OrgMspId): Promise<string> {
     this.logger.log(`Evaluating 'QueryCase' as ${orgMspId} for ID: ${id}`);
     const { gateway, client } = await this.connect(orgMspId);
     try {
       const network = gateway.getNetwork(this.channelName);
       const contract = network.getContract(this.chaincodeName);
-      const evalArgs = this.prepareArgs([id, userRole]);
+      const evalArgs = this.prepareArgs([id]);
       const resultBytes = await contract.evaluateTransaction('QueryCase', ...evalArgs);
       const result = Buffer.from(resultBytes).toString('utf-8');
       this.logger.log(`Transaction 'QueryCase' evaluated successfully.`);
@@ -74,7 +74,7 @@ export class RecordsService {
   ): Promise<void> {
     try {
       // Query case details from chaincode
-      const caseResult = await this.fabricService.queryCase(caseId, userRole, orgMspId);
+      const caseResult = await this.fabricService.queryCase(caseId, orgMspId);
       const caseData = JSON.parse(caseResult);
 
       // Admin has access to all cases
@@ -233,11 +233,7 @@ export class RecordsService {
 
       // 8. Verify the record was created
       this.logger.log(`Querying Fabric to verify...`);
-      const queryResult = await this.fabricService.queryRecord(
-        newRecordId,
-        orgMspId,
-        userRole
-      );
+      const queryResult = await this.fabricService.queryRecord(newRecordId, orgMspId);
       this.logger.log('Record verified successfully.');
 
       return {
@@ -337,9 +333,9 @@ export class RecordsService {
 
     try {
       // 1. Query the blockchain (This will fail if policy denies access)
-      this.logger.log(`Querying Fabric for record ${id} as ${orgMspId} with role ${userRole}...`);
-      // We use the orgMspId and userRole that was passed in
-      const recordJSON = await this.fabricService.queryRecord(id, orgMspId, userRole);
+      this.logger.log(`Querying Fabric for record ${id} as ${orgMspId}...`);
+      // The chaincode takes the role from the gateway identity, not from the request
+      const recordJSON = await this.fabricService.queryRecord(id, orgMspId);
       const record = JSON.parse(recordJSON);
       this.logger.log('Access granted by Fabric.');
 